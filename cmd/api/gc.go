@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/gc"
+	"github.com/pkg/errors"
+	"log"
+	"time"
+)
+
+func collectOrphanedFiles(ctx context.Context, l logger.Logger, svc gc.Service, req gc.CollectOrphanedFilesRequest) error {
+	res, err := svc.CollectOrphanedFiles(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "error on collect orphaned files")
+	}
+
+	verb := "deleted"
+	if req.DryRun {
+		verb = "found"
+	}
+
+	for _, f := range res.Files {
+		l.Info("orphaned file "+verb, logger.Fields{
+			"fileName":  f.Name,
+			"size":      f.Size,
+			"createdAt": f.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	l.Info("garbage collection finished", logger.Fields{
+		"dryRun":         req.DryRun,
+		"files":          len(res.Files),
+		"bytesReclaimed": res.ReclaimedBytes,
+	})
+
+	return nil
+}
+
+func gcCommand(l logger.Logger, svc gc.Service, args []string) {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "delete orphaned files instead of only reporting them")
+	gracePeriod := fs.Duration("grace-period", mustGetDuration("API_GC_GRACE_PERIOD", 24*time.Hour), "keep files newer than this")
+	_ = fs.Parse(args)
+
+	err := collectOrphanedFiles(context.Background(), l, svc, gc.CollectOrphanedFilesRequest{
+		GracePeriod: *gracePeriod,
+		DryRun:      !*apply,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func gcWorker(ctx context.Context, l logger.Logger, svc gc.Service, interval time.Duration, req gc.CollectOrphanedFilesRequest) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// a started run is not interrupted by shutdown, so files are not left half deleted
+			err := collectOrphanedFiles(context.Background(), l, svc, req)
+			if err != nil {
+				l.Error("error on garbage collection", logger.Fields{"error": fmt.Sprintf("%+v", err)})
+			}
+		}
+	}
+}
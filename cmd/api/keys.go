@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"log"
+	"time"
+)
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func keysCommand(l logger.Logger, svc user.Service, args []string) {
+	if len(args) == 0 {
+		log.Fatalln("usage: api keys generate | promote [-overlap 720h] <id> | list")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "generate":
+		key, err := svc.GenerateSigningKey(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		l.Info("signing key generated, promote it after verifiers fetch it", logger.Fields{"id": key.ID})
+	case "promote":
+		fs := flag.NewFlagSet("keys promote", flag.ExitOnError)
+		overlap := fs.Duration("overlap", mustGetDuration("API_SIGNING_KEY_OVERLAP", 30*24*time.Hour), "verify tokens of previous keys for this duration")
+		_ = fs.Parse(args[1:])
+
+		if fs.NArg() != 1 {
+			log.Fatalln("usage: api keys promote [-overlap 720h] <id>")
+		}
+
+		err := svc.PromoteSigningKey(ctx, user.PromoteSigningKeyRequest{KeyID: fs.Arg(0), Overlap: *overlap})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		l.Info("signing key promoted", logger.Fields{"id": fs.Arg(0), "overlap": overlap.String()})
+	case "list":
+		keys, err := svc.ListSigningKeys(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		for i := range keys {
+			l.Info("signing key", logger.Fields{
+				"id":         keys[i].ID,
+				"createdAt":  formatTime(keys[i].CreatedAt),
+				"promotedAt": formatTime(keys[i].PromotedAt),
+				"retiresAt":  formatTime(keys[i].RetiresAt),
+			})
+		}
+	default:
+		log.Fatalf("unknown keys command '%s'\n", args[0])
+	}
+}
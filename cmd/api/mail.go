@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/env"
+	"log"
+	"time"
+)
+
+func mailer(l logger.Logger) mail.Mailer {
+	from := env.GetString("API_MAIL_FROM", "API <no-reply@localhost>")
+
+	switch v := env.GetString("API_MAILER", "log"); v {
+	case "smtp":
+		return mail.NewSMTPMailer(
+			env.MustGetString("API_SMTP_ADDRESS"),
+			env.GetString("API_SMTP_USERNAME", ""),
+			env.GetString("API_SMTP_PASSWORD", ""),
+			from,
+			mustGetDuration("API_SMTP_TIMEOUT", 30*time.Second),
+		)
+	case "file":
+		return mail.NewFileMailer(env.GetString("API_MAIL_DIR", "mails"), from)
+	case "log":
+		if env.IsProduction() {
+			l.Warn("mails are logged instead of being sent, set API_MAILER to smtp", nil)
+		}
+
+		return mail.NewLogMailer(l)
+	default:
+		log.Fatalf("unknown mailer '%s'", v)
+		return nil
+	}
+}
@@ -8,18 +8,74 @@ import (
 	_ "github.com/lib/pq" // import postgres driver
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/api/internal/metrics"
 	"github.com/nasermirzaei89/api/internal/repositories/postgres"
+	"github.com/nasermirzaei89/api/internal/services/audit"
 	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/gc"
+	"github.com/nasermirzaei89/api/internal/services/health"
 	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
 	"github.com/nasermirzaei89/api/internal/services/user"
 	"github.com/nasermirzaei89/api/internal/transport/http"
 	"github.com/nasermirzaei89/env"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	gohttp "net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
+func mustGetDuration(key string, def time.Duration) time.Duration {
+	v := env.GetString(key, "")
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalln(errors.Wrapf(err, "error on parse duration of %s", key))
+	}
+
+	return d
+}
+
+func storageQuotas() map[string]file.Quota {
+	defaults := map[user.Role]file.Quota{
+		user.RoleAdmin:  {},
+		user.RoleAuthor: {MaxBytes: 1 << 30, MaxFiles: 1000},
+	}
+
+	res := make(map[string]file.Quota)
+	for _, role := range user.Roles {
+		prefix := fmt.Sprintf("API_STORAGE_QUOTA_%s_", strings.ToUpper(string(role)))
+		res[string(role)] = file.Quota{
+			MaxBytes: env.GetInt64(prefix+"BYTES", defaults[role].MaxBytes),
+			MaxFiles: env.GetInt64(prefix+"FILES", defaults[role].MaxFiles),
+		}
+	}
+
+	return res
+}
+
+func lockoutPolicy(prefix string, def user.LockoutPolicy) user.LockoutPolicy {
+	return user.LockoutPolicy{
+		BackoffAfter:    env.GetInt(prefix+"BACKOFF_AFTER", def.BackoffAfter),
+		BaseDelay:       mustGetDuration(prefix+"BASE_DELAY", def.BaseDelay),
+		LockoutAfter:    env.GetInt(prefix+"LOCKOUT_AFTER", def.LockoutAfter),
+		LockoutDuration: mustGetDuration(prefix+"LOCKOUT_DURATION", def.LockoutDuration),
+		ResetAfter:      mustGetDuration(prefix+"RESET_AFTER", def.ResetAfter),
+	}
+}
+
 func postgresDB() *sql.DB {
 	db, err := sql.Open("postgres", env.MustGetString("API_POSTGRES_DSN"))
 	if err != nil {
@@ -37,10 +93,18 @@ func postgresDB() *sql.DB {
 	return db
 }
 
-func minioClient(ctx context.Context) *minio.Client {
+func minioClient(ctx context.Context) (*minio.Client, *gohttp.Transport) {
+	secure := env.GetBool("MINIO_SECURE", false)
+
+	transport, err := minio.DefaultTransport(secure)
+	if err != nil {
+		log.Fatalln(errors.Wrap(err, "error on create minio transport"))
+	}
+
 	client, err := minio.New(env.MustGetString("MINIO_ENDPOINT"), &minio.Options{
-		Creds:  credentials.NewStaticV4(env.MustGetString("MINIO_ACCESS_KEY"), env.MustGetString("MINIO_SECRET_KEY"), ""),
-		Secure: env.GetBool("MINIO_SECURE", false),
+		Creds:     credentials.NewStaticV4(env.MustGetString("MINIO_ACCESS_KEY"), env.MustGetString("MINIO_SECRET_KEY"), ""),
+		Secure:    secure,
+		Transport: transport,
 	})
 	if err != nil {
 		log.Fatalln(errors.Wrap(err, "error create new minio client"))
@@ -59,42 +123,257 @@ func minioClient(ctx context.Context) *minio.Client {
 		}
 	}
 
-	return client
+	return client, transport
 }
 
 func main() {
 	// prerequisites
 	// logger
-	l := log.New(os.Stdout, fmt.Sprintln(), 0)
+	logFormat := logger.FormatPretty
+	if env.IsProduction() {
+		logFormat = logger.FormatJSON
+	}
+
+	l := logger.New(os.Stdout, logger.Format(env.GetString("API_LOG_FORMAT", string(logFormat))), logger.ParseLevel(env.GetString("API_LOG_LEVEL", "info")))
 
-	// rsa 256 key pair
-	signKey := env.MustGetString("API_SIGN_KEY")
-	verificationKey := env.MustGetString("API_VERIFICATION_KEY")
+	// tracing
+	shutdownTracing := setupTracing()
+	defer func() {
+		err := shutdownTracing(context.Background())
+		if err != nil {
+			l.Error("error on shutdown tracing", logger.Fields{"error": err})
+		}
+	}()
 
 	// database
 	db := postgresDB()
 
 	// minio
-	mc := minioClient(context.Background())
+	mc, mcTransport := minioClient(context.Background())
 
 	// repositories
 	userRepo := postgres.NewUserRepository(db)
 	postRepo := postgres.NewPostRepository(db)
+	fileRepo := postgres.NewFileRepository(db)
+	loginAttemptRepo := postgres.NewLoginAttemptRepository(db)
+	twoFactorRepo := postgres.NewTwoFactorRepository(db)
+	twoFactorRequirementRepo := postgres.NewTwoFactorRequirementRepository(db)
+	sessionRepo := postgres.NewSessionRepository(db)
+	passwordResetTokenRepo := postgres.NewPasswordResetTokenRepository(db)
+	invitationRepo := postgres.NewInvitationRepository(db)
+	identityRepo := postgres.NewIdentityRepository(db)
+	signingKeyRepo := postgres.NewSigningKeyRepository(db)
+	apiTokenRepo := postgres.NewAPITokenRepository(db)
+	auditEventRepo := postgres.NewAuditEventRepository(db)
 
 	// services
-	userSvc := user.NewService(userRepo, []byte(signKey), []byte(verificationKey))
-	postSvc := post.NewService(postRepo)
-	fileSvc := file.NewService(mc, env.MustGetString("MINIO_BUCKET"))
+	auditSvc := audit.NewTracingService(audit.NewService(auditEventRepo, l))
+	fileSvc := file.NewService(fileRepo, mc, env.MustGetString("MINIO_BUCKET"), storageQuotas(),
+		env.GetStringSlice("API_UPLOAD_ALLOWED_TYPES", []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}),
+		env.GetBool("API_UPLOAD_STRIP_METADATA", true), auditSvc,
+	)
+	postSvc := post.NewTracingService(post.NewService(postRepo, fileSvc, auditSvc))
+
+	// optional rsa 256 key pair
+	signKey := env.GetString("API_SIGN_KEY", "")
+	verificationKey := env.GetString("API_VERIFICATION_KEY", "")
+
+	providers, provisionRoles := oidcProviders()
+
+	// mails are sent in background, so responses don't wait for the mail server or reveal which emails exist
+	mailQueue := mail.NewQueueMailer(mailer(l), l, env.GetInt("API_MAIL_QUEUE_SIZE", 100))
+
+	userSvc := user.NewTracingService(user.NewService(userRepo, user.Config{
+		LoginAttemptRepository:         loginAttemptRepo,
+		TwoFactorRepository:            twoFactorRepo,
+		TwoFactorRequirementRepository: twoFactorRequirementRepo,
+		SessionRepository:              sessionRepo,
+		PasswordResetTokenRepository:   passwordResetTokenRepo,
+		InvitationRepository:           invitationRepo,
+		IdentityRepository:             identityRepo,
+		SigningKeyRepository:           signingKeyRepo,
+		APITokenRepository:             apiTokenRepo,
+		FileService:                    fileSvc,
+		PostService:                    postSvc,
+		Mailer:                         mailQueue,
+		Auditor:                        auditSvc,
+		SignKey:                        []byte(signKey),
+		VerificationKey:                []byte(verificationKey),
+		AccessTokenTTL:                 mustGetDuration("API_ACCESS_TOKEN_TTL", 24*time.Hour),
+		AccountLockout:                 lockoutPolicy("API_LOGIN_ACCOUNT_", user.LockoutPolicy{BackoffAfter: 3, BaseDelay: time.Second, LockoutAfter: 10, LockoutDuration: 15 * time.Minute, ResetAfter: time.Hour}),
+		IPLockout:                      lockoutPolicy("API_LOGIN_IP_", user.LockoutPolicy{BackoffAfter: 10, BaseDelay: time.Second, LockoutAfter: 50, LockoutDuration: time.Hour, ResetAfter: time.Hour}),
+		TOTPIssuer:                     env.GetString("API_TOTP_ISSUER", "API"),
+		PasswordResetURL:               env.GetString("API_PASSWORD_RESET_URL", "http://localhost/reset-password"),
+		PasswordResetTTL:               mustGetDuration("API_PASSWORD_RESET_TTL", time.Hour),
+		InvitationURL:                  env.GetString("API_INVITATION_URL", "http://localhost/accept-invitation"),
+		InvitationTTL:                  mustGetDuration("API_INVITATION_TTL", 7*24*time.Hour),
+		EmailVerificationURL:           env.GetString("API_EMAIL_VERIFICATION_URL", "http://localhost/verify-email"),
+		EmailVerificationTTL:           mustGetDuration("API_EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		OIDCProvisionRoles:             provisionRoles,
+	}))
+
+	gcSvc := gc.NewService(postSvc, fileSvc, userSvc)
+
+	// commands
+	if len(os.Args) > 1 && os.Args[1] == "gc" {
+		gcCommand(l, gcSvc, os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		keysCommand(l, userSvc, os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "users" {
+		usersCommand(l, userSvc, os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "quotas" {
+		quotasCommand(l, userSvc, fileSvc, os.Args[2:])
+		return
+	}
+
+	healthSvc := health.NewService(map[string]health.Check{
+		"postgres": db.PingContext,
+		"minio":    fileSvc.CheckBucket,
+	}, mustGetDuration("API_HEALTH_CHECK_TIMEOUT", 2*time.Second))
+
+	rateLimitSvc := ratelimit.NewService(rateLimitRepository(db))
+
+	// workers
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+
+	go func() {
+		defer workers.Done()
+
+		rateLimitWorker(workersCtx, l, rateLimitSvc, time.Minute)
+	}()
+
+	workers.Add(1)
+
+	go func() {
+		defer workers.Done()
+
+		loginAttemptsWorker(workersCtx, l, userSvc, time.Hour)
+	}()
+
+	workers.Add(1)
+
+	go func() {
+		defer workers.Done()
+
+		mailQueue.Run(workersCtx)
+	}()
+
+	if interval := mustGetDuration("API_GC_INTERVAL", 24*time.Hour); interval > 0 {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+
+			gcWorker(workersCtx, l, gcSvc, interval, gc.CollectOrphanedFilesRequest{
+				GracePeriod: mustGetDuration("API_GC_GRACE_PERIOD", 24*time.Hour),
+				DryRun:      !env.GetBool("API_GC_APPLY", false),
+			})
+		}()
+	}
+
+	// metrics
+	prometheus.MustRegister(metrics.NewDBStatsCollector(db, "api"))
+
+	var metricsHandler gohttp.Handler
+	var metricsSrv *gohttp.Server
+
+	if addr := env.GetString("API_METRICS_ADDRESS", ""); addr != "" {
+		metricsSrv = httpServer("API_METRICS_", addr, promhttp.Handler())
+	} else if env.GetBool("API_METRICS", true) {
+		metricsHandler = promhttp.Handler()
+	}
 
 	// transport
-	h := http.NewHandler(l, userSvc, postSvc, fileSvc,
+	handlerOptions := []http.Option{
 		http.SetGZipLevel(gzip.BestSpeed),
 		http.SetGraphiQL(!env.IsProduction()),
 		http.SetGraphQLPlayground(!env.IsProduction()),
-	)
+		http.SetMaxUploadSize(env.GetInt64("API_MAX_UPLOAD_SIZE", 10<<20)),
+		http.SetLogBody(env.GetBool("API_LOG_BODY", !env.IsProduction())),
+		http.AddLogRedactedHeaders(env.GetStringSlice("API_LOG_REDACTED_HEADERS", nil)...),
+		http.AddLogRedactedFields(env.GetStringSlice("API_LOG_REDACTED_FIELDS", nil)...),
+		http.SetMetrics(prometheus.DefaultRegisterer),
+		http.SetMetricsHandler(metricsHandler),
+		http.SetTrustProxyHeaders(env.GetBool("API_TRUST_PROXY_HEADERS", false)),
+		http.SetOIDC(providers, env.GetString("API_OIDC_CLIENT_URL", "")),
+		http.SetAllowedOrigins(env.GetStringSlice("API_ALLOWED_ORIGINS", nil)),
+		http.SetSessionCookies(env.GetBool("API_SESSION_COOKIES", false), env.GetString("API_SESSION_COOKIE_DOMAIN", "")),
+	}
+
+	if env.GetBool("API_RATE_LIMIT", true) {
+		handlerOptions = append(handlerOptions, http.SetRateLimit(rateLimitSvc, rateLimitPolicies()))
+	}
 
-	err := gohttp.ListenAndServe(env.GetString("API_ADDRESS", ":80"), h)
+	h := http.NewHandler(l, userSvc, postSvc, fileSvc, healthSvc, auditSvc, handlerOptions...)
+
+	srv := httpServer("API_HTTP_", env.GetString("API_ADDRESS", ":80"), h)
+
+	serveErrs := make(chan error, 2)
+
+	go serve(l, "http", srv, serveErrs)
+
+	if metricsSrv != nil {
+		go serve(l, "metrics", metricsSrv, serveErrs)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case s := <-sig:
+		l.Info("shutting down", logger.Fields{"signal": s.String()})
+
+		// report not ready and wait, so load balancers stop routing requests before the server stops
+		healthSvc.SetShuttingDown()
+		time.Sleep(mustGetDuration("API_SHUTDOWN_DELAY", 5*time.Second))
+	case err := <-serveErrs:
+		l.Error("error on serve", logger.Fields{"error": err})
+	}
+
+	// drain in-flight requests until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), mustGetDuration("API_SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	shutdown(ctx, l, "http", srv)
+
+	if metricsSrv != nil {
+		shutdown(ctx, l, "metrics", metricsSrv)
+	}
+
+	// workers stop after their current run
+	stopWorkers()
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-ctx.Done():
+		l.Warn("workers did not stop before the shutdown deadline", nil)
+	}
+
+	// clients close after nothing uses them
+	err := db.Close()
 	if err != nil {
-		log.Fatalln(errors.Wrap(err, "error on listen and serve http"))
+		l.Error("error on close database", logger.Fields{"error": err})
 	}
+
+	mcTransport.CloseIdleConnections()
+
+	l.Info("shut down", nil)
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nasermirzaei89/api/internal/oidc"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/nasermirzaei89/env"
+	"log"
+	"strings"
+)
+
+func oidcProviders() (map[string]*oidc.Provider, map[string]user.Role) {
+	providers := make(map[string]*oidc.Provider)
+	roles := make(map[string]user.Role)
+
+	publicURL := strings.TrimSuffix(env.GetString("API_PUBLIC_URL", "http://localhost"), "/")
+
+	for _, name := range env.GetStringSlice("API_OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		prefix := fmt.Sprintf("API_OIDC_%s_", strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Issuer:       env.MustGetString(prefix + "ISSUER"),
+			ClientID:     env.MustGetString(prefix + "CLIENT_ID"),
+			ClientSecret: env.GetString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  publicURL + "/auth/oidc/" + name + "/callback",
+			Scopes:       env.GetStringSlice(prefix+"SCOPES", nil),
+		}, nil)
+
+		if v := env.GetString(prefix+"ROLE", ""); v != "" {
+			if role := user.Role(v); !role.Valid() {
+				log.Fatalf("invalid role '%s' of %sROLE", v, prefix)
+			}
+
+			roles[name] = user.Role(v)
+		}
+	}
+
+	return providers, roles
+}
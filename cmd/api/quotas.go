@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"log"
+)
+
+func quotasCommand(l logger.Logger, userSvc user.Service, fileSvc file.Service, args []string) {
+	if len(args) == 0 {
+		log.Fatalln("usage: api quotas set [-max-bytes 0] [-max-files 0] <username> | clear <username>")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "set":
+		fs := flag.NewFlagSet("quotas set", flag.ExitOnError)
+		maxBytes := fs.Int64("max-bytes", 0, "max total size of files in bytes, zero is unlimited")
+		maxFiles := fs.Int64("max-files", 0, "max number of files, zero is unlimited")
+		_ = fs.Parse(args[1:])
+
+		if fs.NArg() != 1 {
+			log.Fatalln("usage: api quotas set [-max-bytes 0] [-max-files 0] <username>")
+		}
+
+		entity, err := userSvc.GetUserByUsername(ctx, fs.Arg(0))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = fileSvc.SetStorageQuota(ctx, file.SetStorageQuotaRequest{
+			UserUUID: entity.UUID,
+			Quota:    file.Quota{MaxBytes: *maxBytes, MaxFiles: *maxFiles},
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		l.Info("storage quota set", logger.Fields{"username": entity.Username, "maxBytes": *maxBytes, "maxFiles": *maxFiles})
+	case "clear":
+		if len(args) != 2 {
+			log.Fatalln("usage: api quotas clear <username>")
+		}
+
+		entity, err := userSvc.GetUserByUsername(ctx, args[1])
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		err = fileSvc.DeleteStorageQuota(ctx, entity.UUID)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		l.Info("storage quota cleared, the quota of the role applies", logger.Fields{"username": entity.Username})
+	default:
+		log.Fatalf("unknown quotas command '%s'\n", args[0])
+	}
+}
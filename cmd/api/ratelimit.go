@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/repositories/memory"
+	"github.com/nasermirzaei89/api/internal/repositories/postgres"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/nasermirzaei89/env"
+	"github.com/pkg/errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func rateLimitPolicies() map[string]ratelimit.Policy {
+	res := map[string]ratelimit.Policy{
+		"default":                            {Limit: 600, Window: time.Minute},
+		"POST /files":                        {Limit: 30, Window: time.Minute},
+		"GET /auth/oidc/{provider}/start":    {Limit: 30, Window: time.Minute},
+		"GET /auth/oidc/{provider}/callback": {Limit: 30, Window: time.Minute},
+		"graphql:logIn":                      {Limit: 10, Window: time.Minute},
+		"graphql:verifyTwoFactor":            {Limit: 10, Window: time.Minute},
+		"graphql:requestPasswordReset":       {Limit: 5, Window: time.Minute},
+		"graphql:resetPassword":              {Limit: 10, Window: time.Minute},
+		"graphql:uploadFile":                 {Limit: 30, Window: time.Minute},
+		"graphql:inviteUser":                 {Limit: 30, Window: time.Minute},
+		"graphql:acceptInvitation":           {Limit: 10, Window: time.Minute},
+		"graphql:updateMyEmail":              {Limit: 5, Window: time.Minute},
+		"graphql:requestEmailVerification":   {Limit: 5, Window: time.Minute},
+		"graphql:verifyEmail":                {Limit: 10, Window: time.Minute},
+		"graphql:createApiToken":             {Limit: 10, Window: time.Minute},
+	}
+
+	for _, entry := range env.GetStringSlice("API_RATE_LIMITS", nil) {
+		policy, target, err := parseRateLimitPolicy(entry)
+		if err != nil {
+			log.Fatalln(errors.Wrapf(err, "error on parse rate limit policy '%s'", entry))
+		}
+
+		res[target] = *policy
+	}
+
+	return res
+}
+
+func parseRateLimitPolicy(entry string) (*ratelimit.Policy, string, error) {
+	i := strings.LastIndex(entry, "=")
+	if i < 1 {
+		return nil, "", errors.New("policy should be like <target>=<limit>/<window>")
+	}
+
+	target, spec := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) != 2 {
+		return nil, "", errors.New("policy should be like <target>=<limit>/<window>")
+	}
+
+	limit, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error on parse limit")
+	}
+
+	window, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error on parse window")
+	}
+
+	if limit <= 0 || window <= 0 {
+		return nil, "", errors.New("limit and window should be positive")
+	}
+
+	return &ratelimit.Policy{Limit: limit, Window: window}, target, nil
+}
+
+func rateLimitRepository(db *sql.DB) ratelimit.Repository {
+	switch store := env.GetString("API_RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		return memory.NewRateLimitRepository()
+	case "postgres":
+		return postgres.NewRateLimitRepository(db)
+	default:
+		log.Fatalf("invalid rate limit store '%s'\n", store)
+		return nil
+	}
+}
+
+func rateLimitWorker(ctx context.Context, l logger.Logger, svc ratelimit.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := svc.DeleteExpiredCounters(ctx)
+			if err != nil {
+				l.Error("error on delete expired rate limit counters", logger.Fields{"error": fmt.Sprintf("%+v", err)})
+			}
+		}
+	}
+}
+
+func loginAttemptsWorker(ctx context.Context, l logger.Logger, svc user.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := svc.DeleteExpiredLoginAttempts(ctx)
+			if err != nil {
+				l.Error("error on delete expired login attempts", logger.Fields{"error": fmt.Sprintf("%+v", err)})
+			}
+		}
+	}
+}
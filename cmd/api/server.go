@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/env"
+	"github.com/pkg/errors"
+	gohttp "net/http"
+	"time"
+)
+
+func httpServer(prefix, addr string, h gohttp.Handler) *gohttp.Server {
+	return &gohttp.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: mustGetDuration(prefix+"READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       mustGetDuration(prefix+"READ_TIMEOUT", time.Minute),
+		WriteTimeout:      mustGetDuration(prefix+"WRITE_TIMEOUT", time.Minute),
+		IdleTimeout:       mustGetDuration(prefix+"IDLE_TIMEOUT", 2*time.Minute),
+		MaxHeaderBytes:    env.GetInt(prefix+"MAX_HEADER_BYTES", 1<<20),
+	}
+}
+
+func serve(l logger.Logger, name string, srv *gohttp.Server, errs chan<- error) {
+	l.Info("listening", logger.Fields{"server": name, "address": srv.Addr})
+
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, gohttp.ErrServerClosed) {
+		errs <- errors.Wrapf(err, "error on listen and serve %s", name)
+	}
+}
+
+func shutdown(ctx context.Context, l logger.Logger, name string, srv *gohttp.Server) {
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		l.Error("error on shutdown server", logger.Fields{"server": name, "error": err})
+		_ = srv.Close()
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"github.com/nasermirzaei89/env"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"google.golang.org/grpc/credentials"
+	"log"
+)
+
+func spanExporter() trace.SpanExporter {
+	switch exporter := env.GetString("API_TRACING_EXPORTER", ""); exporter {
+	case "":
+		return nil
+	case "stdout":
+		exp, err := stdout.NewExporter(stdout.WithPrettyPrint(), stdout.WithoutMetricExport())
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "error on create stdout exporter"))
+		}
+
+		return exp
+	case "otlp":
+		opts := []otlp.ExporterOption{otlp.WithAddress(env.GetString("API_OTLP_ENDPOINT", "localhost:55680"))}
+		if env.GetBool("API_OTLP_INSECURE", false) {
+			opts = append(opts, otlp.WithInsecure())
+		} else {
+			opts = append(opts, otlp.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")))
+		}
+
+		exp, err := otlp.NewExporter(opts...)
+		if err != nil {
+			log.Fatalln(errors.Wrap(err, "error on create otlp exporter"))
+		}
+
+		return exp
+	default:
+		log.Fatalf("invalid tracing exporter '%s'\n", exporter)
+		return nil
+	}
+}
+
+func setupTracing() func(ctx context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exp := spanExporter()
+	if exp == nil {
+		return func(ctx context.Context) error { return nil }
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(env.GetFloat64("API_TRACING_SAMPLE_RATIO", 1))),
+		}),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(env.GetString("API_TRACING_SERVICE_NAME", "api")))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"log"
+)
+
+func usersCommand(l logger.Logger, svc user.Service, args []string) {
+	if len(args) != 2 || args[0] != "promote" {
+		log.Fatalln("usage: api users promote <username>")
+	}
+
+	entity, err := svc.PromoteToAdmin(context.Background(), args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	l.Info("user promoted to admin", logger.Fields{"uuid": entity.UUID, "username": entity.Username})
+}
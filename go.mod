@@ -3,6 +3,7 @@ module github.com/nasermirzaei89/api
 go 1.15
 
 require (
+	github.com/buckket/go-blurhash v1.1.0
 	github.com/getsentry/sentry-go v0.8.0
 	github.com/gomarkdown/markdown v0.0.0-20201113031856-722100d81a8e
 	github.com/google/uuid v1.1.2
@@ -16,9 +17,16 @@ require (
 	github.com/lib/pq v1.8.0
 	github.com/logrusorgru/aurora v2.0.3+incompatible
 	github.com/minio/minio-go/v7 v7.0.5
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/nasermirzaei89/env v1.2.0
 	github.com/nasermirzaei89/jwt v0.0.0-20191012203123-932fbb1484a6
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.8.0
+	go.opentelemetry.io/otel v0.14.0
+	go.opentelemetry.io/otel/exporters/otlp v0.14.0
+	go.opentelemetry.io/otel/exporters/stdout v0.14.0
+	go.opentelemetry.io/otel/sdk v0.14.0
 	golang.org/x/crypto v0.0.0-20200709230013-948cd5f35899
 	golang.org/x/net v0.0.0-20200707034311-ab3426394381
+	google.golang.org/grpc v1.32.0
 )
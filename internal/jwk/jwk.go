@@ -0,0 +1,74 @@
+package jwk
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+type Key struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+func NewRSAKey(keyID string, pub *rsa.PublicKey) Key {
+	return Key{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: "RS256",
+		N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func (k Key) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, errors.Errorf("unsupported key type '%s'", k.KeyType)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode modulus")
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode exponent")
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa key")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key
+func (k Key) Thumbprint() string {
+	// members are required members of the key type in lexicographic order, without whitespaces
+	hashed := sha256.Sum256([]byte(`{"e":"` + k.E + `","kty":"` + k.KeyType + `","n":"` + k.N + `"}`))
+
+	return base64.RawURLEncoding.EncodeToString(hashed[:])
+}
+
+func (s Set) Find(keyID string) (Key, bool) {
+	for i := range s.Keys {
+		if s.Keys[i].KeyID == keyID {
+			return s.Keys[i], true
+		}
+	}
+
+	return Key{}, false
+}
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/logrusorgru/aurora"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type logger struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	format Format
+	level  Level
+	fields Fields
+}
+
+func (l *logger) Debug(msg string, fields Fields) {
+	l.Log(LevelDebug, msg, fields)
+}
+
+func (l *logger) Info(msg string, fields Fields) {
+	l.Log(LevelInfo, msg, fields)
+}
+
+func (l *logger) Warn(msg string, fields Fields) {
+	l.Log(LevelWarn, msg, fields)
+}
+
+func (l *logger) Error(msg string, fields Fields) {
+	l.Log(LevelError, msg, fields)
+}
+
+func (l *logger) With(fields Fields) Logger {
+	res := *l
+	res.fields = merge(l.fields, fields)
+
+	return &res
+}
+
+func (l *logger) Log(level Level, msg string, fields Fields) {
+	if level < l.level {
+		return
+	}
+
+	fields = merge(l.fields, fields)
+	now := time.Now()
+
+	var line []byte
+
+	switch l.format {
+	case FormatPretty:
+		line = pretty(now, level, msg, fields)
+	default:
+		line = jsonLine(now, level, msg, fields)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = l.w.Write(line)
+}
+
+func merge(a, b Fields) Fields {
+	res := make(Fields, len(a)+len(b))
+	for k, v := range a {
+		res[k] = v
+	}
+
+	for k, v := range b {
+		res[k] = v
+	}
+
+	return res
+}
+
+func jsonLine(t time.Time, level Level, msg string, fields Fields) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for k, v := range fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+
+		entry[k] = v
+	}
+
+	entry["time"] = t.Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	res, err := json.Marshal(entry)
+	if err != nil {
+		res, _ = json.Marshal(map[string]interface{}{
+			"time":  t.Format(time.RFC3339Nano),
+			"level": LevelError.String(),
+			"msg":   "error on marshal log entry",
+			"error": err.Error(),
+		})
+	}
+
+	return append(res, '\n')
+}
+
+func colorize(level Level, s string) aurora.Value {
+	switch level {
+	case LevelDebug:
+		return aurora.Gray(12, s)
+	case LevelWarn:
+		return aurora.Yellow(s)
+	case LevelError:
+		return aurora.Red(s)
+	default:
+		return aurora.Cyan(s)
+	}
+}
+
+func pretty(t time.Time, level Level, msg string, fields Fields) []byte {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	sb := new(strings.Builder)
+	sb.WriteString(fmt.Sprintf("%s %s %s", t.Format("15:04:05.000"), colorize(level, fmt.Sprintf("%-5s", strings.ToUpper(level.String()))), msg))
+
+	blocks := make([]string, 0)
+
+	for _, k := range keys {
+		v := fmt.Sprintf("%v", fields[k])
+		if strings.Contains(v, "\n") {
+			blocks = append(blocks, fmt.Sprintf("%s:\n%s", aurora.Bold(k), colorize(level, strings.TrimRight(v, "\n"))))
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf(" %s=%s", aurora.Faint(k), v))
+	}
+
+	sb.WriteString("\n")
+
+	for i := range blocks {
+		sb.WriteString(blocks[i])
+		sb.WriteString("\n")
+	}
+
+	return []byte(sb.String())
+}
+
+func New(w io.Writer, format Format, level Level) Logger {
+	l := logger{
+		mu:     new(sync.Mutex),
+		w:      w,
+		format: format,
+		level:  level,
+	}
+
+	return &l
+}
@@ -0,0 +1,8 @@
+package mail
+
+type ErrQueueFull struct {
+}
+
+func (err ErrQueueFull) Error() string {
+	return "mail queue is full, retry later"
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+func (m *fileMailer) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+
+	err := os.MkdirAll(m.dir, 0755)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on make mail directory")
+	}
+
+	name := filepath.Join(m.dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), uuid.New().String()))
+
+	err = ioutil.WriteFile(name, compose(m.from, msg, now), 0600)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on write mail file")
+	}
+
+	return nil
+}
+
+func NewFileMailer(dir, from string) Mailer {
+	m := fileMailer{
+		dir:  dir,
+		from: from,
+	}
+
+	return &m
+}
@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/requestid"
+)
+
+type logMailer struct {
+	logger logger.Logger
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Info("mail", logger.Fields{
+		"to":        msg.To,
+		"subject":   msg.Subject,
+		"text":      msg.Text,
+		"requestID": requestid.FromContext(ctx),
+	})
+
+	return nil
+}
+
+// NewLogMailer logs messages which may have secrets like reset links, so it must not be used in production
+func NewLogMailer(l logger.Logger) Mailer {
+	m := logMailer{
+		logger: l,
+	}
+
+	return &m
+}
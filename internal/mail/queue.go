@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/requestid"
+)
+
+type QueueMailer interface {
+	Mailer
+	Run(ctx context.Context)
+}
+
+type queuedMessage struct {
+	requestID string
+	msg       Message
+}
+
+type queueMailer struct {
+	mailer Mailer
+	logger logger.Logger
+	queue  chan queuedMessage
+}
+
+func (m *queueMailer) Send(ctx context.Context, msg Message) error {
+	select {
+	case m.queue <- queuedMessage{requestID: requestid.FromContext(ctx), msg: msg}:
+		return nil
+	default:
+		return ErrQueueFull{}
+	}
+}
+
+func (m *queueMailer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case qm := <-m.queue:
+					m.send(qm)
+				default:
+					return
+				}
+			}
+		case qm := <-m.queue:
+			m.send(qm)
+		}
+	}
+}
+
+func (m *queueMailer) send(qm queuedMessage) {
+	ctx := requestid.NewContext(context.Background(), qm.requestID)
+
+	err := m.mailer.Send(ctx, qm.msg)
+	if err != nil {
+		m.logger.Error("error on send mail", logger.Fields{
+			"error":     fmt.Sprintf("%+v", err),
+			"subject":   qm.msg.Subject,
+			"requestID": qm.requestID,
+		})
+	}
+}
+
+// NewQueueMailer returns a mailer whose Run must be called to send messages
+func NewQueueMailer(mailer Mailer, l logger.Logger, size int) QueueMailer {
+	m := queueMailer{
+		mailer: mailer,
+		logger: l,
+		queue:  make(chan queuedMessage, size),
+	}
+
+	return &m
+}
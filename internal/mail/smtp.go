@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+type smtpMailer struct {
+	addr    string
+	auth    smtp.Auth
+	from    string
+	timeout time.Duration
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	dialer := net.Dialer{Timeout: m.timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on dial smtp server")
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	// the deadline covers the whole conversation, so a hung server can't block the sender
+	deadline := time.Now().Add(m.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on set deadline")
+	}
+
+	err = m.send(conn, msg)
+	if err != nil {
+		return errors.Wrap(err, "error on send mail")
+	}
+
+	return nil
+}
+
+// send does what smtp.SendMail does on an open connection
+func (m *smtpMailer) send(conn net.Conn, msg Message) error {
+	host, _, _ := net.SplitHostPort(m.addr)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on new client")
+	}
+
+	defer func() {
+		_ = c.Close()
+	}()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return errors.Wrap(errors.WithStack(err), "error on start tls")
+		}
+	}
+
+	if m.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server doesn't support auth")
+		}
+
+		err = c.Auth(m.auth)
+		if err != nil {
+			return errors.Wrap(errors.WithStack(err), "error on auth")
+		}
+	}
+
+	from := m.from
+	if addr, err := netmail.ParseAddress(m.from); err == nil {
+		from = addr.Address
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on mail")
+	}
+
+	for _, to := range msg.To {
+		err = c.Rcpt(to)
+		if err != nil {
+			return errors.Wrap(errors.WithStack(err), "error on rcpt")
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on data")
+	}
+
+	_, err = w.Write(compose(m.from, msg, time.Now()))
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on write message")
+	}
+
+	err = w.Close()
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on close data")
+	}
+
+	err = c.Quit()
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on quit")
+	}
+
+	return nil
+}
+
+func NewSMTPMailer(addr, username, password, from string, timeout time.Duration) Mailer {
+	m := smtpMailer{
+		addr:    addr,
+		from:    from,
+		timeout: timeout,
+	}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &m
+}
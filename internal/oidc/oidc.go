@@ -0,0 +1,404 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/nasermirzaei89/api/internal/jwk"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	leeway              = time.Minute
+	keysRefreshInterval = time.Minute
+	maxResponseSize     = 1 << 20
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Metadata of the provider, see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          jwk.Set
+	keysFetchedAt time.Time
+}
+
+// NewProvider uses a client with a 10 seconds timeout when client is nil
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+	}
+}
+
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on read random bytes")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on new request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on do request")
+	}
+
+	defer func() { _ = rsp.Body.Close() }()
+
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d", rsp.StatusCode)
+	}
+
+	err = json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(v)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on decode response")
+	}
+
+	return nil
+}
+
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var res Metadata
+
+	err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &res)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on get provider metadata")
+	}
+
+	if res.Issuer != p.config.Issuer {
+		return nil, errors.Errorf("issuer of metadata '%s' doesn't match '%s'", res.Issuer, p.config.Issuer)
+	}
+
+	if res.AuthorizationEndpoint == "" || res.TokenEndpoint == "" || res.JWKSURI == "" {
+		return nil, errors.New("provider metadata misses endpoints")
+	}
+
+	p.metadata = &res
+
+	return p.metadata, nil
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "error on discover")
+	}
+
+	link, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on parse authorization endpoint")
+	}
+
+	query := link.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on discover")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	// public clients have no secret and authenticate with the code verifier only
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on new request")
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on do request")
+	}
+
+	defer func() { _ = rsp.Body.Close() }()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(&body)
+	if err != nil {
+		return nil, errors.Wrapf(errors.WithStack(err), "error on decode token response with status code %d", rsp.StatusCode)
+	}
+
+	if rsp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, errors.Errorf("token endpoint responded %d %s: %s", rsp.StatusCode, body.Error, body.ErrorDescription)
+	}
+
+	if body.IDToken == "" {
+		return nil, errors.New("token response has no id token")
+	}
+
+	claims, err := p.VerifyIDToken(ctx, body.IDToken, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on verify id token")
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) key(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on discover")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys.Find(keyID)
+	if !ok && time.Since(p.keysFetchedAt) > keysRefreshInterval {
+		var keys jwk.Set
+
+		err = p.getJSON(ctx, metadata.JWKSURI, &keys)
+		if err != nil {
+			return nil, errors.Wrap(err, "error on get keys")
+		}
+
+		p.keys = keys
+		p.keysFetchedAt = time.Now()
+
+		key, ok = p.keys.Find(keyID)
+	}
+
+	// providers with a single key may omit key ids
+	if !ok && keyID == "" && len(p.keys.Keys) == 1 {
+		key, ok = p.keys.Keys[0], true
+	}
+
+	if !ok {
+		return nil, errors.Errorf("key '%s' not found", keyID)
+	}
+
+	return key.RSAPublicKey()
+}
+
+// audience is a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var arr []string
+	if err := json.Unmarshal(b, &arr); err != nil {
+		return errors.WithStack(err)
+	}
+
+	*a = arr
+
+	return nil
+}
+
+// boolish is a boolean, some providers send `email_verified` as a string
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	*b = boolish(string(data) == "true" || string(data) == `"true"`)
+
+	return nil
+}
+
+// VerifyIDToken follows https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (p *Provider) VerifyIDToken(ctx context.Context, idToken, nonce string) (*Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id token")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on decode header")
+	}
+
+	if header.Algorithm != "RS256" {
+		return nil, errors.Errorf("unsupported algorithm '%s'", header.Algorithm)
+	}
+
+	key, err := p.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on get key")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode signature")
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on verify signature")
+	}
+
+	var payload struct {
+		Issuer            string   `json:"iss"`
+		Subject           string   `json:"sub"`
+		Audience          audience `json:"aud"`
+		AuthorizedParty   string   `json:"azp"`
+		ExpirationTime    int64    `json:"exp"`
+		Nonce             string   `json:"nonce"`
+		Email             string   `json:"email"`
+		EmailVerified     boolish  `json:"email_verified"`
+		PreferredUsername string   `json:"preferred_username"`
+	}
+
+	err = decodeSegment(parts[1], &payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on decode payload")
+	}
+
+	if payload.Issuer != p.config.Issuer {
+		return nil, errors.Errorf("unexpected issuer '%s'", payload.Issuer)
+	}
+
+	validAudience := false
+	for i := range payload.Audience {
+		validAudience = validAudience || payload.Audience[i] == p.config.ClientID
+	}
+
+	if !validAudience || (len(payload.Audience) > 1 && payload.AuthorizedParty != p.config.ClientID) {
+		return nil, errors.New("id token is not issued for the client")
+	}
+
+	if time.Now().Add(-leeway).After(time.Unix(payload.ExpirationTime, 0)) {
+		return nil, errors.New("id token expired")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(payload.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("unexpected nonce")
+	}
+
+	if payload.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+
+	res := Claims{
+		Subject:           payload.Subject,
+		Email:             payload.Email,
+		EmailVerified:     bool(payload.EmailVerified),
+		PreferredUsername: payload.PreferredUsername,
+	}
+
+	return &res, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
+	"sync"
+	"time"
+)
+
+type rateLimitCounter struct {
+	count       int64
+	windowStart time.Time
+	expiresAt   time.Time
+}
+
+// rateLimitRepo keeps counters of a single instance, use the postgres repository when the api is scaled out
+type rateLimitRepo struct {
+	mu       sync.Mutex
+	counters map[string]*rateLimitCounter
+}
+
+func (repo *rateLimitRepo) Increment(_ context.Context, counter ratelimit.Counter) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	c, ok := repo.counters[counter.Key]
+	if !ok || !c.windowStart.Equal(counter.WindowStart) {
+		c = &rateLimitCounter{
+			windowStart: counter.WindowStart,
+			expiresAt:   counter.ExpiresAt,
+		}
+		repo.counters[counter.Key] = c
+	}
+
+	c.count++
+
+	return c.count, nil
+}
+
+func (repo *rateLimitRepo) DeleteExpired(_ context.Context, now time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for key, c := range repo.counters {
+		if !c.expiresAt.After(now) {
+			delete(repo.counters, key)
+		}
+	}
+
+	return nil
+}
+
+func NewRateLimitRepository() ratelimit.Repository {
+	repo := rateLimitRepo{
+		counters: make(map[string]*rateLimitCounter),
+	}
+
+	return &repo
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type apiTokenModel struct {
+	UUID       string
+	UserUUID   string
+	Name       string
+	TokenHash  string
+	Scopes     pq.StringArray
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+func (m apiTokenModel) ToEntity() user.APIToken {
+	entity := user.APIToken{
+		UUID:      m.UUID,
+		UserUUID:  m.UserUUID,
+		Name:      m.Name,
+		TokenHash: m.TokenHash,
+		Scopes:    make([]user.Scope, len(m.Scopes)),
+		CreatedAt: m.CreatedAt,
+	}
+
+	for i := range m.Scopes {
+		entity.Scopes[i] = user.Scope(m.Scopes[i])
+	}
+
+	if m.ExpiresAt.Valid {
+		entity.ExpiresAt = m.ExpiresAt.Time
+	}
+
+	if m.LastUsedAt.Valid {
+		entity.LastUsedAt = m.LastUsedAt.Time
+	}
+
+	if m.RevokedAt.Valid {
+		entity.RevokedAt = m.RevokedAt.Time
+	}
+
+	return entity
+}
+
+func (m *apiTokenModel) FromEntity(entity user.APIToken) {
+	m.UUID = entity.UUID
+	m.UserUUID = entity.UserUUID
+	m.Name = entity.Name
+	m.TokenHash = entity.TokenHash
+	m.Scopes = make(pq.StringArray, len(entity.Scopes))
+	m.CreatedAt = entity.CreatedAt
+	m.ExpiresAt = sql.NullTime{Time: entity.ExpiresAt, Valid: !entity.ExpiresAt.IsZero()}
+	m.LastUsedAt = sql.NullTime{Time: entity.LastUsedAt, Valid: !entity.LastUsedAt.IsZero()}
+	m.RevokedAt = sql.NullTime{Time: entity.RevokedAt, Valid: !entity.RevokedAt.IsZero()}
+
+	for i := range entity.Scopes {
+		m.Scopes[i] = string(entity.Scopes[i])
+	}
+}
+
+type apiTokenRepo struct {
+	db *tracedDB
+}
+
+func (repo *apiTokenRepo) Insert(ctx context.Context, entity user.APIToken) error {
+	m := new(apiTokenModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO api_tokens (uuid, user_uuid, name, token_hash, scopes, created_at, expires_at, last_used_at, revoked_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	args := []interface{}{m.UUID, m.UserUUID, m.Name, m.TokenHash, m.Scopes, m.CreatedAt, m.ExpiresAt, m.LastUsedAt, m.RevokedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *apiTokenRepo) FindByTokenHash(ctx context.Context, tokenHash string) (*user.APIToken, error) {
+	var m apiTokenModel
+
+	// prepare query
+	query := `SELECT uuid, user_uuid, name, token_hash, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_tokens WHERE token_hash = $1;`
+	args := []interface{}{tokenHash}
+	dest := []interface{}{&m.UUID, &m.UserUUID, &m.Name, &m.TokenHash, &m.Scopes, &m.CreatedAt, &m.ExpiresAt, &m.LastUsedAt, &m.RevokedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *apiTokenRepo) ListByUserUUID(ctx context.Context, userUUID string) ([]user.APIToken, error) {
+	query := `SELECT uuid, user_uuid, name, token_hash, scopes, created_at, expires_at, last_used_at, revoked_at FROM api_tokens WHERE user_uuid = $1 AND revoked_at IS NULL ORDER BY created_at DESC;`
+	args := []interface{}{userUUID}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]user.APIToken, 0)
+
+	for rows.Next() {
+		var m apiTokenModel
+
+		err = rows.Scan(&m.UUID, &m.UserUUID, &m.Name, &m.TokenHash, &m.Scopes, &m.CreatedAt, &m.ExpiresAt, &m.LastUsedAt, &m.RevokedAt)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		res = append(res, m.ToEntity())
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
+}
+
+func (repo *apiTokenRepo) UpdateLastUsedAt(ctx context.Context, tokenUUID string, lastUsedAt time.Time) error {
+	query := `UPDATE api_tokens SET last_used_at = $2 WHERE uuid = $1;`
+	args := []interface{}{tokenUUID, lastUsedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *apiTokenRepo) Revoke(ctx context.Context, tokenUUID, userUUID string, revokedAt time.Time) (bool, error) {
+	query := `UPDATE api_tokens SET revoked_at = $3 WHERE uuid = $1 AND user_uuid = $2 AND revoked_at IS NULL;`
+	args := []interface{}{tokenUUID, userUUID, revokedAt}
+
+	res, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on get rows affected")
+	}
+
+	return n > 0, nil
+}
+
+func NewAPITokenRepository(db *sql.DB) user.APITokenRepository {
+	repo := apiTokenRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
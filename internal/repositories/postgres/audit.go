@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/nasermirzaei89/api/internal/services/audit"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type auditEventModel struct {
+	UUID       string
+	ActorUUID  sql.NullString
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Diff       string
+	CreatedAt  time.Time
+}
+
+type changeModel struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+func (m auditEventModel) ToEntity() audit.Event {
+	var diff map[string]changeModel
+	_ = json.Unmarshal([]byte(m.Diff), &diff)
+
+	entity := audit.Event{
+		UUID:       m.UUID,
+		ActorUUID:  m.ActorUUID.String,
+		Action:     m.Action,
+		TargetType: m.TargetType,
+		TargetID:   m.TargetID,
+		IP:         m.IP,
+		UserAgent:  m.UserAgent,
+		Diff:       make(map[string]audit.Change, len(diff)),
+		CreatedAt:  m.CreatedAt,
+	}
+
+	for name, change := range diff {
+		entity.Diff[name] = audit.Change{From: change.From, To: change.To}
+	}
+
+	return entity
+}
+
+func (m *auditEventModel) FromEntity(entity audit.Event) {
+	m.UUID = entity.UUID
+	m.ActorUUID = sql.NullString{String: entity.ActorUUID, Valid: entity.ActorUUID != ""}
+	m.Action = entity.Action
+	m.TargetType = entity.TargetType
+	m.TargetID = entity.TargetID
+	m.IP = entity.IP
+	m.UserAgent = entity.UserAgent
+	m.CreatedAt = entity.CreatedAt
+
+	diff := make(map[string]changeModel, len(entity.Diff))
+	for name, change := range entity.Diff {
+		diff[name] = changeModel{From: change.From, To: change.To}
+	}
+	b, _ := json.Marshal(diff)
+	m.Diff = string(b)
+}
+
+type auditEventRepo struct {
+	db *tracedDB
+}
+
+func (repo *auditEventRepo) Insert(ctx context.Context, entity audit.Event) error {
+	m := new(auditEventModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO audit_events (uuid, actor_uuid, action, target_type, target_id, ip, user_agent, diff, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	args := []interface{}{m.UUID, m.ActorUUID, m.Action, m.TargetType, m.TargetID, m.IP, m.UserAgent, m.Diff, m.CreatedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *auditEventRepo) List(ctx context.Context, filter audit.Filter, after *audit.Cursor, limit int) ([]*audit.Event, error) {
+	var afterCreatedAt sql.NullTime
+	var afterUUID string
+
+	if after != nil {
+		afterCreatedAt = sql.NullTime{Time: after.CreatedAt, Valid: true}
+		afterUUID = after.UUID
+	}
+
+	query := `SELECT uuid, actor_uuid, action, target_type, target_id, ip, user_agent, diff, created_at FROM audit_events WHERE ($1 = '' OR actor_uuid = $1) AND ($2 = '' OR action = $2) AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3) AND ($4::TIMESTAMPTZ IS NULL OR created_at < $4) AND ($5::TIMESTAMPTZ IS NULL OR (created_at, uuid) < ($5, $6)) ORDER BY created_at DESC, uuid DESC LIMIT $7;`
+	args := []interface{}{
+		filter.ActorUUID,
+		filter.Action,
+		sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()},
+		sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()},
+		afterCreatedAt,
+		afterUUID,
+		limit,
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]*audit.Event, 0)
+
+	for rows.Next() {
+		var m auditEventModel
+
+		dest := []interface{}{&m.UUID, &m.ActorUUID, &m.Action, &m.TargetType, &m.TargetID, &m.IP, &m.UserAgent, &m.Diff, &m.CreatedAt}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		entity := m.ToEntity()
+		res = append(res, &entity)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
+}
+
+func NewAuditEventRepository(db *sql.DB) audit.Repository {
+	repo := auditEventRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// tracedDB starts a span for each query, spans of rows end when the query returns, not when rows are read
+type tracedDB struct {
+	db *sql.DB
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := query
+	if i := strings.IndexAny(query, " \n\t"); i > 0 {
+		operation = query[:i]
+	}
+
+	operation = strings.ToUpper(operation)
+
+	return tracing.Start(ctx, "postgres "+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgres,
+		semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(query),
+	))
+}
+
+func (tdb *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := tdb.db.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (tdb *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := tdb.db.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (tdb *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	res := tdb.db.QueryRowContext(ctx, query, args...)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (tdb *tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*tracedTx, error) {
+	tx, err := tdb.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTx{tx: tx}, nil
+}
+
+type tracedTx struct {
+	tx *sql.Tx
+}
+
+func (ttx *tracedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := ttx.tx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (ttx *tracedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	res := ttx.tx.QueryRowContext(ctx, query, args...)
+	tracing.End(span, res.Err())
+
+	return res
+}
+
+func (ttx *tracedTx) Commit() error {
+	return ttx.tx.Commit()
+}
+
+func (ttx *tracedTx) Rollback() error {
+	return ttx.tx.Rollback()
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type fileModel struct {
+	Name          string
+	OwnerUUID     sql.NullString
+	ContentType   string
+	Size          int64
+	Width         sql.NullInt32
+	Height        sql.NullInt32
+	DominantColor string
+	BlurHash      string
+	CreatedAt     time.Time
+}
+
+func (m fileModel) ToEntity() file.Entity {
+	return file.Entity{
+		Name:          m.Name,
+		OwnerUUID:     m.OwnerUUID.String,
+		ContentType:   m.ContentType,
+		Size:          m.Size,
+		Width:         nullIntToPtr(m.Width),
+		Height:        nullIntToPtr(m.Height),
+		DominantColor: m.DominantColor,
+		BlurHash:      m.BlurHash,
+		CreatedAt:     m.CreatedAt,
+	}
+}
+
+func (m *fileModel) FromEntity(entity file.Entity) {
+	m.Name = entity.Name
+	m.OwnerUUID = sql.NullString{String: entity.OwnerUUID, Valid: entity.OwnerUUID != ""}
+	m.ContentType = entity.ContentType
+	m.Size = entity.Size
+	m.Width = ptrToNullInt(entity.Width)
+	m.Height = ptrToNullInt(entity.Height)
+	m.DominantColor = entity.DominantColor
+	m.BlurHash = entity.BlurHash
+	m.CreatedAt = entity.CreatedAt
+}
+
+func nullIntToPtr(v sql.NullInt32) *int {
+	if v.Valid {
+		i := int(v.Int32)
+		return &i
+	}
+
+	return nil
+}
+
+func ptrToNullInt(v *int) sql.NullInt32 {
+	if v != nil {
+		return sql.NullInt32{Int32: int32(*v), Valid: true}
+	}
+
+	return sql.NullInt32{}
+}
+
+type fileRepo struct {
+	db *tracedDB
+}
+
+func (repo *fileRepo) Insert(ctx context.Context, entity file.Entity) error {
+	m := new(fileModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO files (name, owner_uuid, content_type, size, width, height, dominant_color, blur_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	args := []interface{}{m.Name, m.OwnerUUID, m.ContentType, m.Size, m.Width, m.Height, m.DominantColor, m.BlurHash, m.CreatedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *fileRepo) InsertWithinQuota(ctx context.Context, entity file.Entity, quota file.Quota) (*file.Usage, bool, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(errors.WithStack(err), "error on begin tx")
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// locking the owner serializes their uploads, so usage doesn't change until the insert is committed
+	query := `SELECT uuid FROM users WHERE uuid = $1 FOR NO KEY UPDATE;`
+	args := []interface{}{entity.OwnerUUID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	var usage file.Usage
+
+	query = `SELECT COALESCE(SUM(size), 0), COUNT(*) FROM files WHERE owner_uuid = $1;`
+	dest := []interface{}{&usage.Bytes, &usage.Files}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		return nil, false, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	if !quota.Allows(usage, entity.Size) {
+		return &usage, false, nil
+	}
+
+	m := new(fileModel)
+	m.FromEntity(entity)
+
+	query = `INSERT INTO files (name, owner_uuid, content_type, size, width, height, dominant_color, blur_hash, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	args = []interface{}{m.Name, m.OwnerUUID, m.ContentType, m.Size, m.Width, m.Height, m.DominantColor, m.BlurHash, m.CreatedAt}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, false, errors.Wrap(errors.WithStack(err), "error on commit")
+	}
+
+	return &usage, true, nil
+}
+
+func (repo *fileRepo) FindByName(ctx context.Context, name string) (*file.Entity, error) {
+	var m fileModel
+
+	// prepare query
+	query := `SELECT name, owner_uuid, content_type, size, width, height, dominant_color, blur_hash, created_at FROM files WHERE name = $1;`
+	args := []interface{}{name}
+	dest := []interface{}{&m.Name, &m.OwnerUUID, &m.ContentType, &m.Size, &m.Width, &m.Height, &m.DominantColor, &m.BlurHash, &m.CreatedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *fileRepo) DeleteByName(ctx context.Context, name string) error {
+	query := `DELETE FROM files WHERE name = $1;`
+	args := []interface{}{name}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *fileRepo) UpdateOwner(ctx context.Context, fromOwnerUUID, toOwnerUUID string) error {
+	query := `UPDATE files SET owner_uuid = $1 WHERE owner_uuid = $2;`
+	args := []interface{}{sql.NullString{String: toOwnerUUID, Valid: toOwnerUUID != ""}, fromOwnerUUID}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *fileRepo) GetUsageByOwner(ctx context.Context, ownerUUID string) (*file.Usage, error) {
+	var res file.Usage
+
+	// prepare query
+	query := `SELECT COALESCE(SUM(size), 0), COUNT(*) FROM files WHERE owner_uuid = $1;`
+	args := []interface{}{ownerUUID}
+	dest := []interface{}{&res.Bytes, &res.Files}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	return &res, nil
+}
+
+func (repo *fileRepo) FindQuotaByOwner(ctx context.Context, ownerUUID string) (*file.Quota, error) {
+	var res file.Quota
+
+	// prepare query
+	query := `SELECT max_bytes, max_files FROM storage_quotas WHERE owner_uuid = $1;`
+	args := []interface{}{ownerUUID}
+	dest := []interface{}{&res.MaxBytes, &res.MaxFiles}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	return &res, nil
+}
+
+func (repo *fileRepo) SaveQuota(ctx context.Context, ownerUUID string, quota file.Quota) error {
+	query := `INSERT INTO storage_quotas (owner_uuid, max_bytes, max_files) VALUES ($1, $2, $3)
+ON CONFLICT (owner_uuid) DO UPDATE SET max_bytes = excluded.max_bytes, max_files = excluded.max_files;`
+	args := []interface{}{ownerUUID, quota.MaxBytes, quota.MaxFiles}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *fileRepo) DeleteQuotaByOwner(ctx context.Context, ownerUUID string) error {
+	query := `DELETE FROM storage_quotas WHERE owner_uuid = $1;`
+	args := []interface{}{ownerUUID}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewFileRepository(db *sql.DB) file.Repository {
+	repo := fileRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+)
+
+type identityRepo struct {
+	db *tracedDB
+}
+
+func (repo *identityRepo) FindByProviderAndSubject(ctx context.Context, provider, subject string) (*user.Identity, error) {
+	var entity user.Identity
+
+	// prepare query
+	query := `SELECT provider, subject, user_uuid, created_at FROM user_identities WHERE provider = $1 AND subject = $2;`
+	args := []interface{}{provider, subject}
+	dest := []interface{}{&entity.Provider, &entity.Subject, &entity.UserUUID, &entity.CreatedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	return &entity, nil
+}
+
+func (repo *identityRepo) Insert(ctx context.Context, entity user.Identity) error {
+	query := `INSERT INTO user_identities (provider, subject, user_uuid, created_at) VALUES ($1, $2, $3, $4);`
+	args := []interface{}{entity.Provider, entity.Subject, entity.UserUUID, entity.CreatedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewIdentityRepository(db *sql.DB) user.IdentityRepository {
+	repo := identityRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type invitationModel struct {
+	ID          string
+	InviterUUID string
+	Email       string
+	Role        string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      sql.NullTime
+}
+
+func (m invitationModel) ToEntity() user.Invitation {
+	entity := user.Invitation{
+		ID:          m.ID,
+		InviterUUID: m.InviterUUID,
+		Email:       m.Email,
+		Role:        user.Role(m.Role),
+		CreatedAt:   m.CreatedAt,
+		ExpiresAt:   m.ExpiresAt,
+	}
+
+	if m.UsedAt.Valid {
+		entity.UsedAt = m.UsedAt.Time
+	}
+
+	return entity
+}
+
+func (m *invitationModel) FromEntity(entity user.Invitation) {
+	m.ID = entity.ID
+	m.InviterUUID = entity.InviterUUID
+	m.Email = entity.Email
+	m.Role = string(entity.Role)
+	m.CreatedAt = entity.CreatedAt
+	m.ExpiresAt = entity.ExpiresAt
+	m.UsedAt = sql.NullTime{Time: entity.UsedAt, Valid: !entity.UsedAt.IsZero()}
+}
+
+type invitationRepo struct {
+	db *tracedDB
+}
+
+func (repo *invitationRepo) Insert(ctx context.Context, entity user.Invitation) error {
+	m := new(invitationModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO invitations (id, inviter_uuid, email, role, created_at, expires_at, used_at) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	args := []interface{}{m.ID, m.InviterUUID, m.Email, m.Role, m.CreatedAt, m.ExpiresAt, m.UsedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *invitationRepo) FindByID(ctx context.Context, id string) (*user.Invitation, error) {
+	var m invitationModel
+
+	// prepare query
+	query := `SELECT id, inviter_uuid, email, role, created_at, expires_at, used_at FROM invitations WHERE id = $1;`
+	args := []interface{}{id}
+	dest := []interface{}{&m.ID, &m.InviterUUID, &m.Email, &m.Role, &m.CreatedAt, &m.ExpiresAt, &m.UsedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *invitationRepo) MarkUsed(ctx context.Context, id string, usedAt time.Time) (bool, error) {
+	query := `UPDATE invitations SET used_at = $2 WHERE id = $1 AND used_at IS NULL;`
+	args := []interface{}{id, usedAt}
+
+	res, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on get rows affected")
+	}
+
+	return n > 0, nil
+}
+
+func NewInvitationRepository(db *sql.DB) user.InvitationRepository {
+	repo := invitationRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
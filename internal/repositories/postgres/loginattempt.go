@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type loginAttemptsModel struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+func (m loginAttemptsModel) ToEntity() user.LoginAttempts {
+	entity := user.LoginAttempts{
+		Key:           m.Key,
+		Failures:      m.Failures,
+		LastFailureAt: m.LastFailureAt,
+	}
+
+	if m.LockedUntil.Valid {
+		entity.LockedUntil = m.LockedUntil.Time
+	}
+
+	return entity
+}
+
+func (m *loginAttemptsModel) FromEntity(entity user.LoginAttempts) {
+	m.Key = entity.Key
+	m.Failures = entity.Failures
+	m.LastFailureAt = entity.LastFailureAt
+	m.LockedUntil = sql.NullTime{Time: entity.LockedUntil, Valid: !entity.LockedUntil.IsZero()}
+}
+
+type loginAttemptRepo struct {
+	db *tracedDB
+}
+
+func (repo *loginAttemptRepo) FindByKey(ctx context.Context, key string) (*user.LoginAttempts, error) {
+	var m loginAttemptsModel
+
+	// prepare query
+	query := `SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1;`
+	args := []interface{}{key}
+	dest := []interface{}{&m.Key, &m.Failures, &m.LastFailureAt, &m.LockedUntil}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *loginAttemptRepo) Increment(ctx context.Context, key string, failedAt, resetBefore time.Time) (int, error) {
+	var failures int
+
+	// prepare query
+	query := `INSERT INTO login_attempts (key, failures, last_failure_at, locked_until) VALUES ($1, 1, $2, NULL)
+ON CONFLICT (key) DO UPDATE SET
+	failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+	locked_until = CASE WHEN login_attempts.last_failure_at < $3 THEN NULL ELSE login_attempts.locked_until END,
+	last_failure_at = excluded.last_failure_at
+RETURNING failures;`
+	args := []interface{}{key, failedAt, resetBefore}
+	dest := []interface{}{&failures}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		return 0, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	return failures, nil
+}
+
+func (repo *loginAttemptRepo) Lock(ctx context.Context, key string, lockedUntil time.Time) error {
+	query := `UPDATE login_attempts SET locked_until = $2 WHERE key = $1 AND (locked_until IS NULL OR locked_until < $2);`
+	args := []interface{}{key, lockedUntil}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *loginAttemptRepo) DeleteByKey(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE key = $1;`
+	args := []interface{}{key}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *loginAttemptRepo) DeleteExpired(ctx context.Context, failedBefore, now time.Time) error {
+	query := `DELETE FROM login_attempts WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until <= $2);`
+	args := []interface{}{failedBefore, now}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewLoginAttemptRepository(db *sql.DB) user.LoginAttemptRepository {
+	repo := loginAttemptRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+CREATE TABLE files
+(
+    name         TEXT        NOT NULL PRIMARY KEY,
+    owner_uuid   TEXT        NOT NULL REFERENCES users (uuid),
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL,
+    width        INTEGER     NULL,
+    height       INTEGER     NULL,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+
+-- +migrate Down
+
+DROP TABLE files CASCADE;
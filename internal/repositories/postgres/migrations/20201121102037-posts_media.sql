@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE posts
+    ADD COLUMN cover_image JSONB NULL,
+    ADD COLUMN attachments JSONB NOT NULL DEFAULT '[]';
+
+-- +migrate Down
+
+ALTER TABLE posts
+    DROP COLUMN cover_image,
+    DROP COLUMN attachments;
@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'author';
+
+-- +migrate Down
+
+ALTER TABLE users
+    DROP COLUMN role;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+CREATE TABLE storage_quotas
+(
+    owner_uuid TEXT   NOT NULL PRIMARY KEY REFERENCES users (uuid) ON DELETE CASCADE,
+    max_bytes  BIGINT NOT NULL,
+    max_files  BIGINT NOT NULL
+);
+
+CREATE INDEX files_owner_uuid_index ON files (owner_uuid);
+
+-- +migrate Down
+
+DROP INDEX files_owner_uuid_index;
+
+DROP TABLE storage_quotas CASCADE;
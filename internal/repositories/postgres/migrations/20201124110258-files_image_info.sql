@@ -0,0 +1,11 @@
+-- +migrate Up
+
+ALTER TABLE files
+    ADD COLUMN dominant_color TEXT NOT NULL DEFAULT '',
+    ADD COLUMN blur_hash      TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+
+ALTER TABLE files
+    DROP COLUMN dominant_color,
+    DROP COLUMN blur_hash;
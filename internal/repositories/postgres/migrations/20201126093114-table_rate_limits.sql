@@ -0,0 +1,17 @@
+-- +migrate Up
+
+CREATE TABLE rate_limits
+(
+    key          TEXT        NOT NULL PRIMARY KEY,
+    count        BIGINT      NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limits_expires_at_index ON rate_limits (expires_at);
+
+-- +migrate Down
+
+DROP INDEX rate_limits_expires_at_index;
+
+DROP TABLE rate_limits CASCADE;
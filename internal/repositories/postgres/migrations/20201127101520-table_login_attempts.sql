@@ -0,0 +1,13 @@
+-- +migrate Up
+
+CREATE TABLE login_attempts
+(
+    key             TEXT        NOT NULL PRIMARY KEY,
+    failures        INT         NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ NULL
+);
+
+-- +migrate Down
+
+DROP TABLE login_attempts CASCADE;
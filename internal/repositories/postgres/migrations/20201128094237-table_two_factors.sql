@@ -0,0 +1,22 @@
+-- +migrate Up
+
+CREATE TABLE two_factors
+(
+    user_uuid            TEXT        NOT NULL PRIMARY KEY REFERENCES users (uuid) ON DELETE CASCADE,
+    secret               TEXT        NOT NULL,
+    recovery_code_hashes TEXT[]      NOT NULL DEFAULT '{}',
+    last_used_step       BIGINT      NOT NULL DEFAULT 0,
+    created_at           TIMESTAMPTZ NOT NULL,
+    enabled_at           TIMESTAMPTZ NULL
+);
+
+CREATE TABLE two_factor_requirements
+(
+    role TEXT NOT NULL PRIMARY KEY
+);
+
+-- +migrate Down
+
+DROP TABLE two_factor_requirements CASCADE;
+
+DROP TABLE two_factors CASCADE;
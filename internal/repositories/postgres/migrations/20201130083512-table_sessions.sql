@@ -0,0 +1,38 @@
+-- +migrate Up
+
+ALTER TABLE users
+    ADD COLUMN email TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX users_email_index ON users (lower(email)) WHERE email <> '';
+
+CREATE TABLE sessions
+(
+    id         TEXT        NOT NULL PRIMARY KEY,
+    user_uuid  TEXT        NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    ip         TEXT        NOT NULL,
+    user_agent TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX sessions_user_uuid_index ON sessions (user_uuid);
+
+CREATE TABLE password_reset_tokens
+(
+    token_hash TEXT        NOT NULL PRIMARY KEY,
+    user_uuid  TEXT        NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ NULL
+);
+
+-- +migrate Down
+
+DROP TABLE password_reset_tokens CASCADE;
+
+DROP TABLE sessions CASCADE;
+
+DROP INDEX users_email_index;
+
+ALTER TABLE users
+    DROP COLUMN email;
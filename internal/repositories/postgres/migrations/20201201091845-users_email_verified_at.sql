@@ -0,0 +1,14 @@
+-- +migrate Up
+
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMPTZ NULL;
+
+-- emails of existing users were set by admins, so they are trusted
+UPDATE users
+SET email_verified_at = now()
+WHERE email <> '';
+
+-- +migrate Down
+
+ALTER TABLE users
+    DROP COLUMN email_verified_at;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+CREATE TABLE user_identities
+(
+    provider   TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    user_uuid  TEXT        NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX user_identities_user_uuid_index ON user_identities (user_uuid);
+
+-- +migrate Down
+
+DROP TABLE user_identities CASCADE;
@@ -0,0 +1,14 @@
+-- +migrate Up
+
+CREATE TABLE signing_keys
+(
+    id          TEXT        NOT NULL PRIMARY KEY,
+    private_key BYTEA       NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    promoted_at TIMESTAMPTZ NULL,
+    retires_at  TIMESTAMPTZ NULL
+);
+
+-- +migrate Down
+
+DROP TABLE signing_keys CASCADE;
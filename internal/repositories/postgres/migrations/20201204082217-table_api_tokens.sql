@@ -0,0 +1,20 @@
+-- +migrate Up
+
+CREATE TABLE api_tokens
+(
+    uuid         TEXT        NOT NULL PRIMARY KEY,
+    user_uuid    TEXT        NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    token_hash   TEXT        NOT NULL UNIQUE,
+    scopes       TEXT[]      NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NULL,
+    last_used_at TIMESTAMPTZ NULL,
+    revoked_at   TIMESTAMPTZ NULL
+);
+
+CREATE INDEX api_tokens_user_uuid_index ON api_tokens (user_uuid);
+
+-- +migrate Down
+
+DROP TABLE api_tokens CASCADE;
@@ -0,0 +1,17 @@
+-- +migrate Up
+
+ALTER TABLE users
+    ADD COLUMN display_name     TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN bio              TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN avatar_file_name TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN website          TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN social_links     TEXT[] NOT NULL DEFAULT '{}';
+
+-- +migrate Down
+
+ALTER TABLE users
+    DROP COLUMN display_name,
+    DROP COLUMN bio,
+    DROP COLUMN avatar_file_name,
+    DROP COLUMN website,
+    DROP COLUMN social_links;
@@ -0,0 +1,13 @@
+-- +migrate Up
+
+ALTER TABLE posts
+    ADD COLUMN author_uuid TEXT NULL REFERENCES users (uuid) ON DELETE SET NULL;
+
+CREATE INDEX posts_author_uuid_index ON posts (author_uuid);
+
+-- +migrate Down
+
+DROP INDEX posts_author_uuid_index;
+
+ALTER TABLE posts
+    DROP COLUMN author_uuid;
@@ -0,0 +1,16 @@
+-- +migrate Up
+
+ALTER TABLE files
+    ALTER COLUMN owner_uuid DROP NOT NULL,
+    DROP CONSTRAINT files_owner_uuid_fkey,
+    ADD CONSTRAINT files_owner_uuid_fkey FOREIGN KEY (owner_uuid) REFERENCES users (uuid) ON DELETE SET NULL;
+
+-- +migrate Down
+
+-- files of deleted users have no owner, they are removed to restore the constraint
+DELETE FROM files WHERE owner_uuid IS NULL;
+
+ALTER TABLE files
+    ALTER COLUMN owner_uuid SET NOT NULL,
+    DROP CONSTRAINT files_owner_uuid_fkey,
+    ADD CONSTRAINT files_owner_uuid_fkey FOREIGN KEY (owner_uuid) REFERENCES users (uuid);
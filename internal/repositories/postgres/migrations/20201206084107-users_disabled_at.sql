@@ -0,0 +1,9 @@
+-- +migrate Up
+
+ALTER TABLE users
+    ADD COLUMN disabled_at TIMESTAMPTZ NULL;
+
+-- +migrate Down
+
+ALTER TABLE users
+    DROP COLUMN disabled_at;
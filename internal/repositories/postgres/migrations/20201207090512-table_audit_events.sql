@@ -0,0 +1,40 @@
+-- +migrate Up
+
+-- actor_uuid has no foreign key, events are kept after their actors are deleted
+CREATE TABLE audit_events
+(
+    uuid        TEXT        NOT NULL PRIMARY KEY,
+    actor_uuid  TEXT        NULL,
+    action      TEXT        NOT NULL,
+    target_type TEXT        NOT NULL,
+    target_id   TEXT        NOT NULL,
+    ip          TEXT        NOT NULL,
+    user_agent  TEXT        NOT NULL,
+    diff        JSONB       NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_index ON audit_events (created_at);
+CREATE INDEX audit_events_actor_uuid_index ON audit_events (actor_uuid, created_at);
+CREATE INDEX audit_events_action_index ON audit_events (action, created_at);
+
+-- +migrate StatementBegin
+CREATE FUNCTION audit_events_append_only() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE
+    ON audit_events
+    FOR EACH STATEMENT
+EXECUTE PROCEDURE audit_events_append_only();
+
+-- +migrate Down
+
+DROP TABLE audit_events CASCADE;
+
+DROP FUNCTION audit_events_append_only();
@@ -0,0 +1,20 @@
+-- +migrate Up
+
+-- invitations are deleted with their inviter, so their tokens can't be accepted anymore,
+-- tokens sent before this table existed can't be accepted either
+CREATE TABLE invitations
+(
+    id           TEXT        NOT NULL PRIMARY KEY,
+    inviter_uuid TEXT        NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+    email        TEXT        NOT NULL,
+    role         TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    used_at      TIMESTAMPTZ NULL
+);
+
+CREATE INDEX invitations_inviter_uuid_index ON invitations (inviter_uuid);
+
+-- +migrate Down
+
+DROP TABLE invitations CASCADE;
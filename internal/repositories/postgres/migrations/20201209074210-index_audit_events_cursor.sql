@@ -0,0 +1,12 @@
+-- +migrate Up
+
+-- pages of events are ordered by creation time and then uuid
+DROP INDEX audit_events_created_at_index;
+
+CREATE INDEX audit_events_created_at_uuid_index ON audit_events (created_at, uuid);
+
+-- +migrate Down
+
+DROP INDEX audit_events_created_at_uuid_index;
+
+CREATE INDEX audit_events_created_at_index ON audit_events (created_at);
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type passwordResetTokenModel struct {
+	TokenHash string
+	UserUUID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+func (m passwordResetTokenModel) ToEntity() user.PasswordResetToken {
+	entity := user.PasswordResetToken{
+		TokenHash: m.TokenHash,
+		UserUUID:  m.UserUUID,
+		CreatedAt: m.CreatedAt,
+		ExpiresAt: m.ExpiresAt,
+	}
+
+	if m.UsedAt.Valid {
+		entity.UsedAt = m.UsedAt.Time
+	}
+
+	return entity
+}
+
+func (m *passwordResetTokenModel) FromEntity(entity user.PasswordResetToken) {
+	m.TokenHash = entity.TokenHash
+	m.UserUUID = entity.UserUUID
+	m.CreatedAt = entity.CreatedAt
+	m.ExpiresAt = entity.ExpiresAt
+	m.UsedAt = sql.NullTime{Time: entity.UsedAt, Valid: !entity.UsedAt.IsZero()}
+}
+
+type passwordResetTokenRepo struct {
+	db *tracedDB
+}
+
+func (repo *passwordResetTokenRepo) Insert(ctx context.Context, entity user.PasswordResetToken) error {
+	m := new(passwordResetTokenModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO password_reset_tokens (token_hash, user_uuid, created_at, expires_at, used_at) VALUES ($1, $2, $3, $4, $5);`
+	args := []interface{}{m.TokenHash, m.UserUUID, m.CreatedAt, m.ExpiresAt, m.UsedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *passwordResetTokenRepo) FindByTokenHash(ctx context.Context, tokenHash string) (*user.PasswordResetToken, error) {
+	var m passwordResetTokenModel
+
+	// prepare query
+	query := `SELECT token_hash, user_uuid, created_at, expires_at, used_at FROM password_reset_tokens WHERE token_hash = $1;`
+	args := []interface{}{tokenHash}
+	dest := []interface{}{&m.TokenHash, &m.UserUUID, &m.CreatedAt, &m.ExpiresAt, &m.UsedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *passwordResetTokenRepo) MarkUsed(ctx context.Context, tokenHash string, usedAt time.Time) (bool, error) {
+	query := `UPDATE password_reset_tokens SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL;`
+	args := []interface{}{tokenHash, usedAt}
+
+	res, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(errors.WithStack(err), "error on get rows affected")
+	}
+
+	return n > 0, nil
+}
+
+func NewPasswordResetTokenRepository(db *sql.DB) user.PasswordResetTokenRepository {
+	repo := passwordResetTokenRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"github.com/nasermirzaei89/api/internal/services/post"
 	"github.com/pkg/errors"
 	"time"
@@ -10,16 +11,64 @@ import (
 
 type postModel struct {
 	UUID            string
+	AuthorUUID      sql.NullString
 	Title           string
 	Slug            string
 	ContentMarkdown string
 	ContentHTML     string
 	PublishedAt     sql.NullTime
+	CoverImage      sql.NullString
+	Attachments     string
+}
+
+type mediaModel struct {
+	FileName   string           `json:"fileName"`
+	AltText    string           `json:"altText"`
+	Caption    string           `json:"caption"`
+	FocalPoint *focalPointModel `json:"focalPoint,omitempty"`
+}
+
+type focalPointModel struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (m mediaModel) ToEntity() post.Media {
+	res := post.Media{
+		FileName: m.FileName,
+		AltText:  m.AltText,
+		Caption:  m.Caption,
+	}
+
+	if m.FocalPoint != nil {
+		res.FocalPoint = &post.FocalPoint{X: m.FocalPoint.X, Y: m.FocalPoint.Y}
+	}
+
+	return res
+}
+
+func (m *mediaModel) FromEntity(entity post.Media) {
+	m.FileName = entity.FileName
+	m.AltText = entity.AltText
+	m.Caption = entity.Caption
+	m.FocalPoint = nil
+	if entity.FocalPoint != nil {
+		m.FocalPoint = &focalPointModel{X: entity.FocalPoint.X, Y: entity.FocalPoint.Y}
+	}
 }
 
 func (m postModel) ToEntity() post.Entity {
+	var coverImage *mediaModel
+	if m.CoverImage.Valid {
+		_ = json.Unmarshal([]byte(m.CoverImage.String), &coverImage)
+	}
+
+	var attachments []mediaModel
+	_ = json.Unmarshal([]byte(m.Attachments), &attachments)
+
 	return post.Entity{
 		UUID:            m.UUID,
+		AuthorUUID:      m.AuthorUUID.String,
 		Title:           m.Title,
 		Slug:            m.Slug,
 		ContentMarkdown: m.ContentMarkdown,
@@ -32,11 +81,28 @@ func (m postModel) ToEntity() post.Entity {
 
 			return nil
 		}(),
+		CoverImage: func() *post.Media {
+			if coverImage != nil {
+				v := coverImage.ToEntity()
+				return &v
+			}
+
+			return nil
+		}(),
+		Attachments: func() []post.Media {
+			res := make([]post.Media, len(attachments))
+			for i := range attachments {
+				res[i] = attachments[i].ToEntity()
+			}
+
+			return res
+		}(),
 	}
 }
 
 func (m *postModel) FromEntity(entity post.Entity) {
 	m.UUID = entity.UUID
+	m.AuthorUUID = sql.NullString{String: entity.AuthorUUID, Valid: entity.AuthorUUID != ""}
 	m.Title = entity.Title
 	m.Slug = entity.Slug
 	m.ContentMarkdown = entity.ContentMarkdown
@@ -45,18 +111,33 @@ func (m *postModel) FromEntity(entity post.Entity) {
 	if entity.PublishedAt != nil {
 		m.PublishedAt.Time = *entity.PublishedAt
 	}
+
+	m.CoverImage = sql.NullString{}
+	if entity.CoverImage != nil {
+		var coverImage mediaModel
+		coverImage.FromEntity(*entity.CoverImage)
+		b, _ := json.Marshal(coverImage)
+		m.CoverImage = sql.NullString{String: string(b), Valid: true}
+	}
+
+	attachments := make([]mediaModel, len(entity.Attachments))
+	for i := range entity.Attachments {
+		attachments[i].FromEntity(entity.Attachments[i])
+	}
+	b, _ := json.Marshal(attachments)
+	m.Attachments = string(b)
 }
 
 type postRepo struct {
-	db *sql.DB
+	db *tracedDB
 }
 
 func (repo *postRepo) UpdateByUUID(ctx context.Context, uuid string, entity post.Entity) error {
 	m := new(postModel)
 	m.FromEntity(entity)
 
-	query := `UPDATE posts SET uuid = $1, title = $2, slug = $3, content_markdown = $4, content_html = $5, published_at = $6 WHERE uuid = $7;`
-	args := []interface{}{m.UUID, m.Title, m.Slug, m.ContentMarkdown, m.ContentHTML, m.PublishedAt, uuid}
+	query := `UPDATE posts SET uuid = $1, author_uuid = $2, title = $3, slug = $4, content_markdown = $5, content_html = $6, published_at = $7, cover_image = $8, attachments = $9 WHERE uuid = $10;`
+	args := []interface{}{m.UUID, m.AuthorUUID, m.Title, m.Slug, m.ContentMarkdown, m.ContentHTML, m.PublishedAt, m.CoverImage, m.Attachments, uuid}
 
 	_, err := repo.db.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -67,7 +148,7 @@ func (repo *postRepo) UpdateByUUID(ctx context.Context, uuid string, entity post
 }
 
 func (repo *postRepo) List(ctx context.Context) ([]*post.Entity, error) {
-	query := `SELECT uuid, title, slug, content_markdown, content_html, published_at FROM posts;`
+	query := `SELECT uuid, author_uuid, title, slug, content_markdown, content_html, published_at, cover_image, attachments FROM posts;`
 
 	rows, err := repo.db.QueryContext(ctx, query)
 	if err != nil {
@@ -77,7 +158,7 @@ func (repo *postRepo) List(ctx context.Context) ([]*post.Entity, error) {
 	res := make([]*post.Entity, 0)
 	for rows.Next() {
 		var m postModel
-		dest := []interface{}{&m.UUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt}
+		dest := []interface{}{&m.UUID, &m.AuthorUUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt, &m.CoverImage, &m.Attachments}
 		err = rows.Scan(dest...)
 		if err != nil {
 			return nil, errors.Wrap(err, "error on scan row")
@@ -91,7 +172,7 @@ func (repo *postRepo) List(ctx context.Context) ([]*post.Entity, error) {
 }
 
 func (repo *postRepo) ListPublished(ctx context.Context) ([]*post.Entity, error) {
-	query := `SELECT uuid, title, slug, content_markdown, content_html, published_at FROM posts WHERE published_at IS NOT NULL;`
+	query := `SELECT uuid, author_uuid, title, slug, content_markdown, content_html, published_at, cover_image, attachments FROM posts WHERE published_at IS NOT NULL;`
 
 	rows, err := repo.db.QueryContext(ctx, query)
 	if err != nil {
@@ -101,7 +182,7 @@ func (repo *postRepo) ListPublished(ctx context.Context) ([]*post.Entity, error)
 	res := make([]*post.Entity, 0)
 	for rows.Next() {
 		var m postModel
-		dest := []interface{}{&m.UUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt}
+		dest := []interface{}{&m.UUID, &m.AuthorUUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt, &m.CoverImage, &m.Attachments}
 		err = rows.Scan(dest...)
 		if err != nil {
 			return nil, errors.Wrap(err, "error on scan row")
@@ -118,9 +199,9 @@ func (repo *postRepo) FindByUUID(ctx context.Context, uuid string) (*post.Entity
 	var m postModel
 
 	// prepare query
-	query := `SELECT uuid, title, slug, content_markdown, content_html, published_at FROM posts WHERE uuid = $1;`
+	query := `SELECT uuid, author_uuid, title, slug, content_markdown, content_html, published_at, cover_image, attachments FROM posts WHERE uuid = $1;`
 	args := []interface{}{uuid}
-	dest := []interface{}{&m.UUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt}
+	dest := []interface{}{&m.UUID, &m.AuthorUUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt, &m.CoverImage, &m.Attachments}
 
 	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
 	if err != nil {
@@ -139,9 +220,9 @@ func (repo *postRepo) FindBySlug(ctx context.Context, slug string) (*post.Entity
 	var m postModel
 
 	// prepare query
-	query := `SELECT uuid, title, slug, content_markdown, content_html, published_at FROM posts WHERE slug = $1;`
+	query := `SELECT uuid, author_uuid, title, slug, content_markdown, content_html, published_at, cover_image, attachments FROM posts WHERE slug = $1;`
 	args := []interface{}{slug}
-	dest := []interface{}{&m.UUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt}
+	dest := []interface{}{&m.UUID, &m.AuthorUUID, &m.Title, &m.Slug, &m.ContentMarkdown, &m.ContentHTML, &m.PublishedAt, &m.CoverImage, &m.Attachments}
 
 	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
 	if err != nil {
@@ -160,8 +241,20 @@ func (repo *postRepo) Insert(ctx context.Context, entity post.Entity) error {
 	m := new(postModel)
 	m.FromEntity(entity)
 
-	query := `INSERT INTO posts (uuid, title, slug, content_markdown, content_html, published_at) VALUES ($1, $2, $3, $4, $5, $6);`
-	args := []interface{}{m.UUID, m.Title, m.Slug, m.ContentMarkdown, m.ContentHTML, m.PublishedAt}
+	query := `INSERT INTO posts (uuid, author_uuid, title, slug, content_markdown, content_html, published_at, cover_image, attachments) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	args := []interface{}{m.UUID, m.AuthorUUID, m.Title, m.Slug, m.ContentMarkdown, m.ContentHTML, m.PublishedAt, m.CoverImage, m.Attachments}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *postRepo) UpdateAuthor(ctx context.Context, fromUserUUID, toUserUUID string) error {
+	query := `UPDATE posts SET author_uuid = $1 WHERE author_uuid = $2;`
+	args := []interface{}{sql.NullString{String: toUserUUID, Valid: toUserUUID != ""}, fromUserUUID}
 
 	_, err := repo.db.ExecContext(ctx, query, args...)
 	if err != nil {
@@ -173,7 +266,7 @@ func (repo *postRepo) Insert(ctx context.Context, entity post.Entity) error {
 
 func NewPostRepository(db *sql.DB) post.Repository {
 	repo := postRepo{
-		db: db,
+		db: &tracedDB{db: db},
 	}
 
 	return &repo
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type rateLimitRepo struct {
+	db *tracedDB
+}
+
+func (repo *rateLimitRepo) Increment(ctx context.Context, counter ratelimit.Counter) (int64, error) {
+	var count int64
+
+	// prepare query
+	query := `INSERT INTO rate_limits (key, count, window_start, expires_at) VALUES ($1, 1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET
+	count = CASE WHEN rate_limits.window_start = excluded.window_start THEN rate_limits.count + 1 ELSE 1 END,
+	window_start = excluded.window_start,
+	expires_at = excluded.expires_at
+RETURNING count;`
+	args := []interface{}{counter.Key, counter.WindowStart, counter.ExpiresAt}
+	dest := []interface{}{&count}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		return 0, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	return count, nil
+}
+
+func (repo *rateLimitRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	query := `DELETE FROM rate_limits WHERE expires_at <= $1;`
+	args := []interface{}{now}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewRateLimitRepository(db *sql.DB) ratelimit.Repository {
+	repo := rateLimitRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
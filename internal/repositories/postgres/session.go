@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type sessionModel struct {
+	ID        string
+	UserUUID  string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+func (m sessionModel) ToEntity() user.Session {
+	entity := user.Session{
+		ID:        m.ID,
+		UserUUID:  m.UserUUID,
+		IP:        m.IP,
+		UserAgent: m.UserAgent,
+		CreatedAt: m.CreatedAt,
+	}
+
+	if m.RevokedAt.Valid {
+		entity.RevokedAt = m.RevokedAt.Time
+	}
+
+	return entity
+}
+
+func (m *sessionModel) FromEntity(entity user.Session) {
+	m.ID = entity.ID
+	m.UserUUID = entity.UserUUID
+	m.IP = entity.IP
+	m.UserAgent = entity.UserAgent
+	m.CreatedAt = entity.CreatedAt
+	m.RevokedAt = sql.NullTime{Time: entity.RevokedAt, Valid: !entity.RevokedAt.IsZero()}
+}
+
+type sessionRepo struct {
+	db *tracedDB
+}
+
+func (repo *sessionRepo) Insert(ctx context.Context, entity user.Session) error {
+	m := new(sessionModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO sessions (id, user_uuid, ip, user_agent, created_at, revoked_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	args := []interface{}{m.ID, m.UserUUID, m.IP, m.UserAgent, m.CreatedAt, m.RevokedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) FindByID(ctx context.Context, id string) (*user.Session, error) {
+	var m sessionModel
+
+	// prepare query
+	query := `SELECT id, user_uuid, ip, user_agent, created_at, revoked_at FROM sessions WHERE id = $1;`
+	args := []interface{}{id}
+	dest := []interface{}{&m.ID, &m.UserUUID, &m.IP, &m.UserAgent, &m.CreatedAt, &m.RevokedAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *sessionRepo) RevokeByID(ctx context.Context, id string, revokedAt time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $2 WHERE id = $1 AND revoked_at IS NULL;`
+	args := []interface{}{id, revokedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *sessionRepo) RevokeByUserUUID(ctx context.Context, userUUID, exceptID string, revokedAt time.Time) error {
+	query := `UPDATE sessions SET revoked_at = $3 WHERE user_uuid = $1 AND id <> $2 AND revoked_at IS NULL;`
+	args := []interface{}{userUUID, exceptID, revokedAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewSessionRepository(db *sql.DB) user.SessionRepository {
+	repo := sessionRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type signingKeyModel struct {
+	ID         string
+	PrivateKey []byte
+	CreatedAt  time.Time
+	PromotedAt sql.NullTime
+	RetiresAt  sql.NullTime
+}
+
+func (m signingKeyModel) ToEntity() user.SigningKey {
+	entity := user.SigningKey{
+		ID:         m.ID,
+		PrivateKey: m.PrivateKey,
+		CreatedAt:  m.CreatedAt,
+	}
+
+	if m.PromotedAt.Valid {
+		entity.PromotedAt = m.PromotedAt.Time
+	}
+
+	if m.RetiresAt.Valid {
+		entity.RetiresAt = m.RetiresAt.Time
+	}
+
+	return entity
+}
+
+func (m *signingKeyModel) FromEntity(entity user.SigningKey) {
+	m.ID = entity.ID
+	m.PrivateKey = entity.PrivateKey
+	m.CreatedAt = entity.CreatedAt
+	m.PromotedAt = sql.NullTime{Time: entity.PromotedAt, Valid: !entity.PromotedAt.IsZero()}
+	m.RetiresAt = sql.NullTime{Time: entity.RetiresAt, Valid: !entity.RetiresAt.IsZero()}
+}
+
+type signingKeyRepo struct {
+	db *tracedDB
+}
+
+func (repo *signingKeyRepo) Insert(ctx context.Context, entity user.SigningKey) error {
+	m := new(signingKeyModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO signing_keys (id, private_key, created_at, promoted_at, retires_at) VALUES ($1, $2, $3, $4, $5);`
+	args := []interface{}{m.ID, m.PrivateKey, m.CreatedAt, m.PromotedAt, m.RetiresAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *signingKeyRepo) List(ctx context.Context) ([]user.SigningKey, error) {
+	query := `SELECT id, private_key, created_at, promoted_at, retires_at FROM signing_keys ORDER BY created_at;`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]user.SigningKey, 0)
+
+	for rows.Next() {
+		var m signingKeyModel
+
+		err = rows.Scan(&m.ID, &m.PrivateKey, &m.CreatedAt, &m.PromotedAt, &m.RetiresAt)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		res = append(res, m.ToEntity())
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
+}
+
+func (repo *signingKeyRepo) Promote(ctx context.Context, id string, promotedAt, retiresAt time.Time) error {
+	// a single statement, so concurrent promotions can't leave two keys signing without retirement
+	query := `UPDATE signing_keys
+SET promoted_at = CASE WHEN id = $1 THEN $2 ELSE promoted_at END,
+    retires_at  = CASE WHEN id = $1 THEN NULL ELSE $3 END
+WHERE id = $1 OR (promoted_at IS NOT NULL AND retires_at IS NULL);`
+	args := []interface{}{id, promotedAt, retiresAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewSigningKeyRepository(db *sql.DB) user.SigningKeyRepository {
+	repo := signingKeyRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
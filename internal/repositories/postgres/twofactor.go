@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type twoFactorModel struct {
+	UserUUID           string
+	Secret             string
+	RecoveryCodeHashes pq.StringArray
+	LastUsedStep       int64
+	CreatedAt          time.Time
+	EnabledAt          sql.NullTime
+}
+
+func (m twoFactorModel) ToEntity() user.TwoFactor {
+	entity := user.TwoFactor{
+		UserUUID:           m.UserUUID,
+		Secret:             m.Secret,
+		RecoveryCodeHashes: []string(m.RecoveryCodeHashes),
+		LastUsedStep:       m.LastUsedStep,
+		CreatedAt:          m.CreatedAt,
+	}
+
+	if m.EnabledAt.Valid {
+		entity.EnabledAt = m.EnabledAt.Time
+	}
+
+	return entity
+}
+
+func (m *twoFactorModel) FromEntity(entity user.TwoFactor) {
+	m.UserUUID = entity.UserUUID
+	m.Secret = entity.Secret
+	m.RecoveryCodeHashes = pq.StringArray(entity.RecoveryCodeHashes)
+	m.LastUsedStep = entity.LastUsedStep
+	m.CreatedAt = entity.CreatedAt
+	m.EnabledAt = sql.NullTime{Time: entity.EnabledAt, Valid: !entity.EnabledAt.IsZero()}
+
+	if m.RecoveryCodeHashes == nil {
+		m.RecoveryCodeHashes = pq.StringArray{}
+	}
+}
+
+type twoFactorRepo struct {
+	db *tracedDB
+}
+
+func (repo *twoFactorRepo) FindByUserUUID(ctx context.Context, userUUID string) (*user.TwoFactor, error) {
+	var m twoFactorModel
+
+	// prepare query
+	query := `SELECT user_uuid, secret, recovery_code_hashes, last_used_step, created_at, enabled_at FROM two_factors WHERE user_uuid = $1;`
+	args := []interface{}{userUUID}
+	dest := []interface{}{&m.UserUUID, &m.Secret, &m.RecoveryCodeHashes, &m.LastUsedStep, &m.CreatedAt, &m.EnabledAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *twoFactorRepo) Save(ctx context.Context, entity user.TwoFactor) error {
+	m := new(twoFactorModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO two_factors (user_uuid, secret, recovery_code_hashes, last_used_step, created_at, enabled_at) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_uuid) DO UPDATE SET secret = excluded.secret, recovery_code_hashes = excluded.recovery_code_hashes, last_used_step = excluded.last_used_step, created_at = excluded.created_at, enabled_at = excluded.enabled_at;`
+	args := []interface{}{m.UserUUID, m.Secret, m.RecoveryCodeHashes, m.LastUsedStep, m.CreatedAt, m.EnabledAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *twoFactorRepo) DeleteByUserUUID(ctx context.Context, userUUID string) error {
+	query := `DELETE FROM two_factors WHERE user_uuid = $1;`
+	args := []interface{}{userUUID}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewTwoFactorRepository(db *sql.DB) user.TwoFactorRepository {
+	repo := twoFactorRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
+
+type twoFactorRequirementRepo struct {
+	db *tracedDB
+}
+
+func (repo *twoFactorRequirementRepo) ListRequiredRoles(ctx context.Context) ([]user.Role, error) {
+	query := `SELECT role FROM two_factor_requirements ORDER BY role;`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]user.Role, 0)
+
+	for rows.Next() {
+		var role user.Role
+
+		err = rows.Scan(&role)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		res = append(res, role)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
+}
+
+func (repo *twoFactorRequirementRepo) SetRequired(ctx context.Context, role user.Role, required bool) error {
+	query := `DELETE FROM two_factor_requirements WHERE role = $1;`
+	if required {
+		query = `INSERT INTO two_factor_requirements (role) VALUES ($1) ON CONFLICT (role) DO NOTHING;`
+	}
+
+	args := []interface{}{role}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func NewTwoFactorRequirementRepository(db *sql.DB) user.TwoFactorRequirementRepository {
+	repo := twoFactorRequirementRepo{
+		db: &tracedDB{db: db},
+	}
+
+	return &repo
+}
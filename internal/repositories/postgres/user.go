@@ -3,21 +3,99 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"github.com/lib/pq"
 	"github.com/nasermirzaei89/api/internal/services/user"
 	"github.com/pkg/errors"
+	"strings"
 )
 
+type userModel struct {
+	UUID            string
+	Username        string
+	Email           string
+	EmailVerifiedAt sql.NullTime
+	PasswordHash    string
+	Role            string
+	DisplayName     string
+	Bio             string
+	AvatarFileName  string
+	Website         string
+	SocialLinks     pq.StringArray
+	DisabledAt      sql.NullTime
+}
+
+func (m userModel) ToEntity() user.Entity {
+	entity := user.Entity{
+		UUID:         m.UUID,
+		Username:     m.Username,
+		Email:        m.Email,
+		PasswordHash: m.PasswordHash,
+		Role:         user.Role(m.Role),
+		Profile: user.Profile{
+			DisplayName:    m.DisplayName,
+			Bio:            m.Bio,
+			AvatarFileName: m.AvatarFileName,
+			Website:        m.Website,
+			SocialLinks:    []string(m.SocialLinks),
+		},
+	}
+
+	if m.EmailVerifiedAt.Valid {
+		entity.EmailVerifiedAt = m.EmailVerifiedAt.Time
+	}
+
+	if m.DisabledAt.Valid {
+		entity.DisabledAt = m.DisabledAt.Time
+	}
+
+	return entity
+}
+
+func (m *userModel) FromEntity(entity user.Entity) {
+	m.UUID = entity.UUID
+	m.Username = entity.Username
+	m.Email = entity.Email
+	m.EmailVerifiedAt = sql.NullTime{Time: entity.EmailVerifiedAt, Valid: !entity.EmailVerifiedAt.IsZero()}
+	m.PasswordHash = entity.PasswordHash
+	m.Role = string(entity.Role)
+	m.DisplayName = entity.Profile.DisplayName
+	m.Bio = entity.Profile.Bio
+	m.AvatarFileName = entity.Profile.AvatarFileName
+	m.Website = entity.Profile.Website
+	m.SocialLinks = pq.StringArray(entity.Profile.SocialLinks)
+	m.DisabledAt = sql.NullTime{Time: entity.DisabledAt, Valid: !entity.DisabledAt.IsZero()}
+
+	if m.SocialLinks == nil {
+		m.SocialLinks = pq.StringArray{}
+	}
+}
+
 type userRepo struct {
-	db *sql.DB
+	db *tracedDB
+}
+
+func (repo *userRepo) Insert(ctx context.Context, entity user.Entity) error {
+	m := new(userModel)
+	m.FromEntity(entity)
+
+	query := `INSERT INTO users (uuid, username, email, email_verified_at, password_hash, role, display_name, bio, avatar_file_name, website, social_links, disabled_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);`
+	args := []interface{}{m.UUID, m.Username, m.Email, m.EmailVerifiedAt, m.PasswordHash, m.Role, m.DisplayName, m.Bio, m.AvatarFileName, m.Website, m.SocialLinks, m.DisabledAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
 }
 
 func (repo *userRepo) FindByUsername(ctx context.Context, username string) (*user.Entity, error) {
-	var model user.Entity
+	var m userModel
 
 	// prepare query
-	query := `SELECT uuid, username, password_hash FROM users WHERE username = $1;`
+	query := `SELECT uuid, username, email, email_verified_at, password_hash, role, display_name, bio, avatar_file_name, website, social_links, disabled_at FROM users WHERE username = $1;`
 	args := []interface{}{username}
-	dest := []interface{}{&model.UUID, &model.Username, &model.PasswordHash}
+	dest := []interface{}{&m.UUID, &m.Username, &m.Email, &m.EmailVerifiedAt, &m.PasswordHash, &m.Role, &m.DisplayName, &m.Bio, &m.AvatarFileName, &m.Website, &m.SocialLinks, &m.DisabledAt}
 
 	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
 	if err != nil {
@@ -27,16 +105,18 @@ func (repo *userRepo) FindByUsername(ctx context.Context, username string) (*use
 		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
 	}
 
-	return &model, nil
+	entity := m.ToEntity()
+
+	return &entity, nil
 }
 
 func (repo *userRepo) FindByUUID(ctx context.Context, userUUID string) (*user.Entity, error) {
-	var model user.Entity
+	var m userModel
 
 	// prepare query
-	query := `SELECT uuid, username, password_hash FROM users WHERE uuid = $1;`
+	query := `SELECT uuid, username, email, email_verified_at, password_hash, role, display_name, bio, avatar_file_name, website, social_links, disabled_at FROM users WHERE uuid = $1;`
 	args := []interface{}{userUUID}
-	dest := []interface{}{&model.UUID, &model.Username, &model.PasswordHash}
+	dest := []interface{}{&m.UUID, &m.Username, &m.Email, &m.EmailVerifiedAt, &m.PasswordHash, &m.Role, &m.DisplayName, &m.Bio, &m.AvatarFileName, &m.Website, &m.SocialLinks, &m.DisabledAt}
 
 	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
 	if err != nil {
@@ -46,12 +126,134 @@ func (repo *userRepo) FindByUUID(ctx context.Context, userUUID string) (*user.En
 		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
 	}
 
-	return &model, nil
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *userRepo) FindByEmail(ctx context.Context, email string) (*user.Entity, error) {
+	var m userModel
+
+	// prepare query
+	query := `SELECT uuid, username, email, email_verified_at, password_hash, role, display_name, bio, avatar_file_name, website, social_links, disabled_at FROM users WHERE lower(email) = lower($1) AND email <> '';`
+	args := []interface{}{email}
+	dest := []interface{}{&m.UUID, &m.Username, &m.Email, &m.EmailVerifiedAt, &m.PasswordHash, &m.Role, &m.DisplayName, &m.Bio, &m.AvatarFileName, &m.Website, &m.SocialLinks, &m.DisabledAt}
+
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(errors.WithStack(err), "error on query row")
+	}
+
+	entity := m.ToEntity()
+
+	return &entity, nil
+}
+
+func (repo *userRepo) UpdateByUUID(ctx context.Context, userUUID string, entity user.Entity) error {
+	m := new(userModel)
+	m.FromEntity(entity)
+
+	query := `UPDATE users SET username = $2, email = $3, email_verified_at = $4, password_hash = $5, role = $6, display_name = $7, bio = $8, avatar_file_name = $9, website = $10, social_links = $11, disabled_at = $12 WHERE uuid = $1;`
+	args := []interface{}{userUUID, m.Username, m.Email, m.EmailVerifiedAt, m.PasswordHash, m.Role, m.DisplayName, m.Bio, m.AvatarFileName, m.Website, m.SocialLinks, m.DisabledAt}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+func (repo *userRepo) DeleteByUUID(ctx context.Context, userUUID string) error {
+	query := `DELETE FROM users WHERE uuid = $1;`
+	args := []interface{}{userUUID}
+
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on exec")
+	}
+
+	return nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (repo *userRepo) Search(ctx context.Context, search string) ([]*user.Entity, error) {
+	query := `SELECT uuid, username, email, email_verified_at, password_hash, role, display_name, bio, avatar_file_name, website, social_links, disabled_at FROM users WHERE username ILIKE $1 OR email ILIKE $1 OR display_name ILIKE $1 ORDER BY username;`
+	args := []interface{}{"%" + likeEscaper.Replace(search) + "%"}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]*user.Entity, 0)
+
+	for rows.Next() {
+		var m userModel
+
+		dest := []interface{}{&m.UUID, &m.Username, &m.Email, &m.EmailVerifiedAt, &m.PasswordHash, &m.Role, &m.DisplayName, &m.Bio, &m.AvatarFileName, &m.Website, &m.SocialLinks, &m.DisabledAt}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		entity := m.ToEntity()
+		res = append(res, &entity)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
+}
+
+func (repo *userRepo) ListAvatarFileNames(ctx context.Context) ([]string, error) {
+	query := `SELECT avatar_file_name FROM users WHERE avatar_file_name <> '';`
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on query")
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	res := make([]string, 0)
+
+	for rows.Next() {
+		var fileName string
+
+		err = rows.Scan(&fileName)
+		if err != nil {
+			return nil, errors.Wrap(errors.WithStack(err), "error on scan row")
+		}
+
+		res = append(res, fileName)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on iterate rows")
+	}
+
+	return res, nil
 }
 
 func NewUserRepository(db *sql.DB) user.Repository {
 	repo := userRepo{
-		db: db,
+		db: &tracedDB{db: db},
 	}
 
 	return &repo
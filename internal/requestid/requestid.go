@@ -0,0 +1,80 @@
+package requestid
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+type contextKey string
+
+const contextKeyRequestID contextKey = "requestID"
+
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID, requestID)
+}
+
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	v, _ := ctx.Value(contextKeyRequestID).(string)
+
+	return v
+}
+
+type withRequestID struct {
+	cause     error
+	requestID string
+}
+
+func (w *withRequestID) Error() string {
+	return w.cause.Error()
+}
+
+func (w *withRequestID) Cause() error {
+	return w.cause
+}
+
+func (w *withRequestID) Unwrap() error {
+	return w.cause
+}
+
+func (w *withRequestID) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			_, _ = fmt.Fprintf(s, "%+v\nrequest id: %s", w.Cause(), w.requestID)
+			return
+		}
+
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, w.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", w.Error())
+	}
+}
+
+func Wrap(ctx context.Context, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+
+	if requestID := FromContext(ctx); requestID != "" && Of(err) == "" {
+		err = &withRequestID{cause: err, requestID: requestID}
+	}
+
+	return errors.Wrap(err, message)
+}
+
+func Of(err error) string {
+	var w *withRequestID
+	if errors.As(err, &w) {
+		return w.requestID
+	}
+
+	return ""
+}
@@ -0,0 +1,33 @@
+package audit
+
+import "time"
+
+type Event struct {
+	UUID string
+	// ActorUUID is empty for anonymous callers and commands
+	ActorUUID  string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Diff       map[string]Change
+	CreatedAt  time.Time
+}
+
+type Change struct {
+	From interface{}
+	To   interface{}
+}
+
+type Filter struct {
+	ActorUUID string
+	Action    string
+	From      time.Time
+	To        time.Time
+}
+
+type Cursor struct {
+	CreatedAt time.Time
+	UUID      string
+}
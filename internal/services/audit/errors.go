@@ -0,0 +1,8 @@
+package audit
+
+type ErrPermissionDenied struct {
+}
+
+func (err ErrPermissionDenied) Error() string {
+	return "permission denied"
+}
@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/user"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type service struct {
+	repo   Repository
+	logger logger.Logger
+}
+
+// record inserts the event, failures are logged instead of failing the audited request
+func (svc *service) record(ctx context.Context, event Event) {
+	event.UUID = uuid.New().String()
+
+	err := svc.repo.Insert(ctx, event)
+	if err != nil {
+		svc.logger.Error("error on insert audit event", logger.Fields{
+			"error":     err,
+			"action":    event.Action,
+			"actorUUID": event.ActorUUID,
+			"requestID": requestid.FromContext(ctx),
+		})
+	}
+}
+
+func (svc *service) AuditLogin(ctx context.Context, event user.LoginEvent) {
+	action := "user.login"
+	diff := map[string]Change{"username": {From: nil, To: event.Username}}
+
+	if !event.Success {
+		action = "user.login_failed"
+		diff["reason"] = Change{From: nil, To: event.Reason}
+	}
+
+	if event.TwoFactor {
+		diff["twoFactor"] = Change{From: nil, To: true}
+	}
+
+	if event.Provider != "" {
+		diff["provider"] = Change{From: nil, To: event.Provider}
+	}
+
+	svc.record(ctx, Event{
+		ActorUUID:  event.UserUUID,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   event.UserUUID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		Diff:       diff,
+		CreatedAt:  event.CreatedAt,
+	})
+}
+
+func (svc *service) AuditAdminAction(ctx context.Context, event user.AdminEvent) {
+	diff := make(map[string]Change, len(event.Changes))
+	for name, change := range event.Changes {
+		diff[name] = Change{From: change.From, To: change.To}
+	}
+
+	svc.record(ctx, Event{
+		ActorUUID:  event.ActorUUID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		Diff:       diff,
+		CreatedAt:  event.CreatedAt,
+	})
+}
+
+func (svc *service) AuditPostAction(ctx context.Context, event post.Event) {
+	diff := make(map[string]Change, len(event.Changes))
+	for name, change := range event.Changes {
+		diff[name] = Change{From: change.From, To: change.To}
+	}
+
+	svc.record(ctx, Event{
+		ActorUUID:  event.ActorUUID,
+		Action:     event.Action,
+		TargetType: "post",
+		TargetID:   event.PostUUID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		Diff:       diff,
+		CreatedAt:  event.CreatedAt,
+	})
+}
+
+func (svc *service) AuditFileAction(ctx context.Context, event file.Event) {
+	diff := make(map[string]Change, len(event.Changes))
+	for name, change := range event.Changes {
+		diff[name] = Change{From: change.From, To: change.To}
+	}
+
+	svc.record(ctx, Event{
+		ActorUUID:  event.ActorUUID,
+		Action:     event.Action,
+		TargetType: "file",
+		TargetID:   event.FileName,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		Diff:       diff,
+		CreatedAt:  event.CreatedAt,
+	})
+}
+
+func (svc *service) ListEvents(ctx context.Context, req ListEventsRequest) (*ListEventsResponse, error) {
+	if req.UserRole != user.RoleAdmin {
+		return nil, ErrPermissionDenied{}
+	}
+
+	if req.First <= 0 {
+		req.First = defaultPageSize
+	}
+
+	if req.First > maxPageSize {
+		req.First = maxPageSize
+	}
+
+	// one more event is loaded to know whether there is a next page
+	events, err := svc.repo.List(ctx, req.Filter, req.After, req.First+1)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list audit events")
+	}
+
+	res := ListEventsResponse{
+		Events:      events,
+		HasNextPage: len(events) > req.First,
+	}
+
+	if res.HasNextPage {
+		res.Events = events[:req.First]
+	}
+
+	return &res, nil
+}
+
+func NewService(repo Repository, l logger.Logger) Service {
+	svc := service{
+		repo:   repo,
+		logger: l,
+	}
+
+	return &svc
+}
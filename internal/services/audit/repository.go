@@ -0,0 +1,10 @@
+package audit
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Insert(ctx context.Context, event Event) (err error)
+	List(ctx context.Context, filter Filter, after *Cursor, limit int) (res []*Event, err error)
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/user"
+)
+
+type Service interface {
+	AuditLogin(ctx context.Context, event user.LoginEvent)
+	AuditAdminAction(ctx context.Context, event user.AdminEvent)
+	AuditPostAction(ctx context.Context, event post.Event)
+	AuditFileAction(ctx context.Context, event file.Event)
+	ListEvents(ctx context.Context, req ListEventsRequest) (res *ListEventsResponse, err error)
+}
+
+type ListEventsRequest struct {
+	UserRole user.Role
+	Filter   Filter
+	First    int
+	After    *Cursor
+}
+
+type ListEventsResponse struct {
+	Events      []*Event
+	HasNextPage bool
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracingService struct {
+	next Service
+}
+
+func (svc *tracingService) AuditLogin(ctx context.Context, event user.LoginEvent) {
+	ctx, span := tracing.Start(ctx, "audit.AuditLogin")
+	svc.next.AuditLogin(ctx, event)
+	span.End()
+}
+
+func (svc *tracingService) AuditAdminAction(ctx context.Context, event user.AdminEvent) {
+	ctx, span := tracing.Start(ctx, "audit.AuditAdminAction", trace.WithAttributes(label.String("audit.action", event.Action)))
+	svc.next.AuditAdminAction(ctx, event)
+	span.End()
+}
+
+func (svc *tracingService) AuditPostAction(ctx context.Context, event post.Event) {
+	ctx, span := tracing.Start(ctx, "audit.AuditPostAction", trace.WithAttributes(label.String("audit.action", event.Action)))
+	svc.next.AuditPostAction(ctx, event)
+	span.End()
+}
+
+func (svc *tracingService) AuditFileAction(ctx context.Context, event file.Event) {
+	ctx, span := tracing.Start(ctx, "audit.AuditFileAction", trace.WithAttributes(label.String("audit.action", event.Action)))
+	svc.next.AuditFileAction(ctx, event)
+	span.End()
+}
+
+func (svc *tracingService) ListEvents(ctx context.Context, req ListEventsRequest) (*ListEventsResponse, error) {
+	ctx, span := tracing.Start(ctx, "audit.ListEvents", trace.WithAttributes(label.Int("audit.first", req.First)))
+	res, err := svc.next.ListEvents(ctx, req)
+
+	if res != nil {
+		span.SetAttributes(label.Int("audit.event_count", len(res.Events)))
+	}
+
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func NewTracingService(next Service) Service {
+	svc := tracingService{
+		next: next,
+	}
+
+	return &svc
+}
@@ -0,0 +1,45 @@
+package file
+
+import "time"
+
+type Entity struct {
+	Name          string
+	OwnerUUID     string
+	ContentType   string
+	Size          int64
+	Width         *int
+	Height        *int
+	DominantColor string
+	BlurHash      string
+	CreatedAt     time.Time
+}
+
+type Event struct {
+	ActorUUID string
+	Action    string
+	FileName  string
+	IP        string
+	UserAgent string
+	Changes   map[string]Change
+	CreatedAt time.Time
+}
+
+type Change struct {
+	From interface{}
+	To   interface{}
+}
+
+// Quota limits storage of a user, zero values mean unlimited
+type Quota struct {
+	MaxBytes int64
+	MaxFiles int64
+}
+
+func (q Quota) Allows(usage Usage, size int64) bool {
+	return (q.MaxBytes == 0 || usage.Bytes+size <= q.MaxBytes) && (q.MaxFiles == 0 || usage.Files+1 <= q.MaxFiles)
+}
+
+type Usage struct {
+	Bytes int64
+	Files int64
+}
@@ -0,0 +1,71 @@
+package file
+
+import "fmt"
+
+type ErrFileWithNameNotFound struct {
+	Name string
+}
+
+func (err ErrFileWithNameNotFound) Error() string {
+	return fmt.Sprintf("file with name '%s' not found", err.Name)
+}
+
+type ErrStorageQuotaExceeded struct {
+	Usage Usage
+	Quota Quota
+}
+
+func (err ErrStorageQuotaExceeded) Error() string {
+	return fmt.Sprintf("storage quota exceeded, %d of %d bytes and %d of %d files used", err.Usage.Bytes, err.Quota.MaxBytes, err.Usage.Files, err.Quota.MaxFiles)
+}
+
+type ErrInvalidStorageQuota struct {
+	Quota Quota
+}
+
+func (err ErrInvalidStorageQuota) Error() string {
+	return fmt.Sprintf("invalid storage quota of %d bytes and %d files", err.Quota.MaxBytes, err.Quota.MaxFiles)
+}
+
+type ErrUnsupportedContentType struct {
+	ContentType string
+}
+
+func (err ErrUnsupportedContentType) Error() string {
+	return fmt.Sprintf("content type '%s' is not supported", err.ContentType)
+}
+
+type ErrContentTypeMismatch struct {
+	Declared string
+	Detected string
+}
+
+func (err ErrContentTypeMismatch) Error() string {
+	return fmt.Sprintf("declared content type '%s' does not match detected content type '%s'", err.Declared, err.Detected)
+}
+
+type ErrInvalidImage struct {
+	Reason string
+}
+
+func (err ErrInvalidImage) Error() string {
+	return fmt.Sprintf("invalid image: %s", err.Reason)
+}
+
+type ErrImageTooLarge struct {
+	Width     int
+	Height    int
+	MaxPixels int64
+}
+
+func (err ErrImageTooLarge) Error() string {
+	return fmt.Sprintf("image of %dx%d pixels exceeds %d pixels", err.Width, err.Height, err.MaxPixels)
+}
+
+type ErrBucketNotFound struct {
+	Name string
+}
+
+func (err ErrBucketNotFound) Error() string {
+	return fmt.Sprintf("bucket '%s' not found", err.Name)
+}
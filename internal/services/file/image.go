@@ -0,0 +1,272 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/buckket/go-blurhash"
+	"github.com/pkg/errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+const thumbnailSize = 64
+
+// maxImagePixels limits decoding, small files with huge dimensions could exhaust memory
+const maxImagePixels = 50 * 1000 * 1000
+
+type imageInfo struct {
+	Width         int
+	Height        int
+	DominantColor string
+	BlurHash      string
+}
+
+func checkImageSize(content []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return ErrImageTooLarge{Width: cfg.Width, Height: cfg.Height, MaxPixels: maxImagePixels}
+	}
+
+	return nil
+}
+
+func processImage(content []byte, contentType string, strip bool) ([]byte, *imageInfo, error) {
+	img, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.WithStack(err), "error on decode image")
+	}
+
+	if strip && (format == "jpeg" || format == "png") {
+		if format == "jpeg" {
+			img = applyOrientation(img, jpegOrientation(content))
+		}
+
+		buf := new(bytes.Buffer)
+
+		switch format {
+		case "jpeg":
+			err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 90})
+		case "png":
+			err = png.Encode(buf, img)
+		}
+
+		if err != nil {
+			return nil, nil, errors.Wrapf(errors.WithStack(err), "error on encode %s", contentType)
+		}
+
+		content = buf.Bytes()
+	}
+
+	thumb := thumbnail(img, thumbnailSize)
+
+	hash, err := blurhash.Encode(4, 3, thumb)
+	if err != nil {
+		return nil, nil, errors.Wrap(errors.WithStack(err), "error on encode blurhash")
+	}
+
+	info := imageInfo{
+		Width:         img.Bounds().Dx(),
+		Height:        img.Bounds().Dy(),
+		DominantColor: dominantColor(thumb),
+		BlurHash:      hash,
+	}
+
+	return content, &info, nil
+}
+
+func jpegOrientation(content []byte) int {
+	if len(content) < 4 || content[0] != 0xFF || content[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(content); {
+		if content[i] != 0xFF {
+			return 1
+		}
+
+		marker := content[i+1]
+		size := int(binary.BigEndian.Uint16(content[i+2:]))
+
+		// start of scan, no more metadata
+		if marker == 0xDA || size < 2 || i+2+size > len(content) {
+			return 1
+		}
+
+		segment := content[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+
+		i += 2 + size
+	}
+
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		// orientation tag
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v < 1 || v > 8 {
+				return 1
+			}
+
+			return v
+		}
+	}
+
+	return 1
+}
+
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// orientations 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w > h {
+			tw, th = size, h*size/w
+		} else {
+			tw, th = w*size/h, size
+		}
+	}
+
+	if tw < 1 {
+		tw = 1
+	}
+
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*w/tw, b.Min.Y+y*h/th))
+		}
+	}
+
+	return dst
+}
+
+func dominantColor(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+
+	buckets := make(map[int]*bucket)
+	var top *bucket
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				continue
+			}
+
+			key := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+
+			bk, ok := buckets[key]
+			if !ok {
+				bk = new(bucket)
+				buckets[key] = bk
+			}
+
+			bk.count++
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+
+			if top == nil || bk.count > top.count {
+				top = bk
+			}
+		}
+	}
+
+	if top == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", top.r/top.count, top.g/top.count, top.b/top.count)
+}
@@ -6,31 +6,200 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	_ "image/gif" // register gif decoder
 	"io"
 	"mime"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type service struct {
-	mc         *minio.Client
-	bucketName string
+	repo          Repository
+	mc            *minio.Client
+	bucketName    string
+	roleQuotas    map[string]Quota
+	allowedTypes  []string
+	stripMetadata bool
+	auditor       Auditor
 }
 
-func (svc *service) UploadFile(ctx context.Context, r io.Reader) (*UploadFileResponse, error) {
+func (svc *service) audit(ctx context.Context, event Event) {
+	if svc.auditor == nil {
+		return
+	}
+
+	svc.auditor.AuditFileAction(ctx, event)
+}
+
+// mediaTypeAliases map names sent by clients to names of http.DetectContentType
+var mediaTypeAliases = map[string]string{
+	"image/jpg":         "image/jpeg",
+	"image/pjpeg":       "image/jpeg",
+	"image/x-png":       "image/png",
+	"image/x-ms-bmp":    "image/bmp",
+	"application/x-pdf": "application/pdf",
+	"audio/mp3":         "audio/mpeg",
+	"audio/wav":         "audio/wave",
+	"audio/x-wav":       "audio/wave",
+}
+
+func mediaType(contentType string) string {
+	v, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		v = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	if alias, ok := mediaTypeAliases[v]; ok {
+		return alias
+	}
+
+	return v
+}
+
+// text subtypes are detected as text/plain and unknown binaries as application/octet-stream
+func mediaTypeMatches(declared, detected string) bool {
+	switch {
+	case declared == "", declared == "application/octet-stream", declared == detected:
+		return true
+	case detected == "text/plain":
+		return strings.HasPrefix(declared, "text/")
+	default:
+		return false
+	}
+}
+
+func (svc *service) checkContentType(declared string, content []byte) (string, error) {
+	contentType := http.DetectContentType(content)
+	detected := mediaType(contentType)
+
+	if !mediaTypeMatches(mediaType(declared), detected) {
+		return "", ErrContentTypeMismatch{Declared: declared, Detected: detected}
+	}
+
+	if len(svc.allowedTypes) == 0 {
+		return contentType, nil
+	}
+
+	for i := range svc.allowedTypes {
+		if mediaType(svc.allowedTypes[i]) == detected {
+			return contentType, nil
+		}
+	}
+
+	return "", ErrUnsupportedContentType{ContentType: detected}
+}
+
+func (svc *service) quota(ctx context.Context, userUUID, userRole string) (*Quota, error) {
+	quota, err := svc.repo.FindQuotaByOwner(ctx, userUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find quota by owner")
+	}
+
+	if quota == nil {
+		q := svc.roleQuotas[userRole]
+		quota = &q
+	}
+
+	return quota, nil
+}
+
+func (svc *service) GetStorageUsage(ctx context.Context, req GetStorageUsageRequest) (*GetStorageUsageResponse, error) {
+	quota, err := svc.quota(ctx, req.UserUUID, req.UserRole)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on get quota")
+	}
+
+	usage, err := svc.repo.GetUsageByOwner(ctx, req.UserUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on get usage by owner")
+	}
+
+	rsp := GetStorageUsageResponse{
+		Usage: *usage,
+		Quota: *quota,
+	}
+
+	return &rsp, nil
+}
+
+func (svc *service) SetStorageQuota(ctx context.Context, req SetStorageQuotaRequest) error {
+	if req.Quota.MaxBytes < 0 || req.Quota.MaxFiles < 0 {
+		return ErrInvalidStorageQuota{Quota: req.Quota}
+	}
+
+	err := svc.repo.SaveQuota(ctx, req.UserUUID, req.Quota)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on save quota")
+	}
+
+	return nil
+}
+
+func (svc *service) DeleteStorageQuota(ctx context.Context, userUUID string) error {
+	err := svc.repo.DeleteQuotaByOwner(ctx, userUUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete quota by owner")
+	}
+
+	return nil
+}
+
+func (svc *service) UploadFile(ctx context.Context, req UploadFileRequest) (*Entity, error) {
 	buf := new(bytes.Buffer)
 
-	fileSize, err := buf.ReadFrom(r)
+	fileSize, err := buf.ReadFrom(req.Reader)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on read from input")
+	}
+
+	contentType, err := svc.checkContentType(req.ContentType, buf.Bytes())
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on check content type")
+	}
+
+	content := buf.Bytes()
+
+	var info *imageInfo
+
+	// dimensions are checked before images are decoded
+	if strings.HasPrefix(contentType, "image/") {
+		err = checkImageSize(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch mediaType(contentType) {
+	case "image/jpeg", "image/png":
+		content, info, err = processImage(content, contentType, svc.stripMetadata)
+		if err != nil {
+			return nil, ErrInvalidImage{Reason: err.Error()}
+		}
+	case "image/gif":
+		if processed, gifInfo, processErr := processImage(content, contentType, false); processErr == nil {
+			content, info = processed, gifInfo
+		}
+	}
+
+	fileSize = int64(len(content))
+
+	storage, err := svc.GetStorageUsage(ctx, GetStorageUsageRequest{UserUUID: req.UserUUID, UserRole: req.UserRole})
 	if err != nil {
-		return nil, errors.Wrap(errors.WithStack(err), "error on read from input")
+		return nil, requestid.Wrap(ctx, err, "error on get storage usage")
 	}
 
-	contentType := http.DetectContentType(buf.Bytes())
+	if !storage.Quota.Allows(storage.Usage, fileSize) {
+		return nil, ErrStorageQuotaExceeded{Usage: storage.Usage, Quota: storage.Quota}
+	}
 
 	exts, err := mime.ExtensionsByType(contentType)
 	if err != nil {
-		return nil, errors.Wrap(errors.WithStack(err), "error on get extensions by type")
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on get extensions by type")
 	}
 
 	ext := ""
@@ -41,42 +210,202 @@ func (svc *service) UploadFile(ctx context.Context, r io.Reader) (*UploadFileRes
 
 	fileName := fmt.Sprintf("%s%s", uuid.New().String(), ext)
 
-	_, err = svc.mc.PutObject(ctx, svc.bucketName, fileName, buf, fileSize, minio.PutObjectOptions{
+	entity := Entity{
+		Name:        fileName,
+		OwnerUUID:   req.UserUUID,
+		ContentType: contentType,
+		Size:        fileSize,
+		CreatedAt:   time.Now(),
+	}
+
+	if info != nil {
+		entity.Width = &info.Width
+		entity.Height = &info.Height
+		entity.DominantColor = info.DominantColor
+		entity.BlurHash = info.BlurHash
+	}
+
+	spanCtx, span := svc.startObjectSpan(ctx, "PutObject", fileName)
+	_, err = svc.mc.PutObject(spanCtx, svc.bucketName, fileName, bytes.NewReader(content), fileSize, minio.PutObjectOptions{
 		ContentType:    contentType,
 		SendContentMd5: false,
 	})
+	tracing.End(span, err)
+
 	if err != nil {
-		return nil, errors.Wrap(errors.WithStack(err), "error on put object")
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on put object")
 	}
 
-	rsp := UploadFileResponse{
-		FileName: fileName,
+	// concurrent uploads may have used the quota since it was checked
+	usage, ok, err := svc.repo.InsertWithinQuota(ctx, entity, storage.Quota)
+	if err != nil || !ok {
+		svc.removeObject(ctx, fileName)
+
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on insert file within quota")
+		}
+
+		return nil, ErrStorageQuotaExceeded{Usage: *usage, Quota: storage.Quota}
 	}
-	return &rsp, nil
+
+	svc.audit(ctx, Event{
+		ActorUUID: req.UserUUID,
+		Action:    "file.upload",
+		FileName:  entity.Name,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		Changes: map[string]Change{
+			"contentType": {From: nil, To: entity.ContentType},
+			"size":        {From: nil, To: entity.Size},
+		},
+		CreatedAt: entity.CreatedAt,
+	})
+
+	return &entity, nil
+}
+
+// the garbage collection removes the object if this fails
+func (svc *service) removeObject(ctx context.Context, fileName string) {
+	spanCtx, span := svc.startObjectSpan(ctx, "RemoveObject", fileName)
+	err := svc.mc.RemoveObject(spanCtx, svc.bucketName, fileName, minio.RemoveObjectOptions{})
+	tracing.End(span, err)
+}
+
+func (svc *service) GetFileByName(ctx context.Context, fileName string) (*Entity, error) {
+	entity, err := svc.repo.FindByName(ctx, fileName)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find file by name")
+	}
+
+	if entity == nil {
+		return nil, ErrFileWithNameNotFound{Name: fileName}
+	}
+
+	return entity, nil
 }
 
 func (svc *service) DownloadFile(ctx context.Context, fileName string) (io.ReadSeeker, error) {
-	res, err := svc.mc.GetObject(ctx, svc.bucketName, fileName, minio.GetObjectOptions{})
+	spanCtx, span := svc.startObjectSpan(ctx, "GetObject", fileName)
+	res, err := svc.mc.GetObject(spanCtx, svc.bucketName, fileName, minio.GetObjectOptions{})
+	tracing.End(span, err)
+
 	if err != nil {
-		return nil, errors.Wrap(errors.WithStack(err), "error on get object")
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on get object")
 	}
 
 	return res, nil
 }
 
 func (svc *service) GetFileLastModified(ctx context.Context, fileName string) (*time.Time, error) {
-	res, err := svc.mc.StatObject(ctx, svc.bucketName, fileName, minio.StatObjectOptions{})
+	spanCtx, span := svc.startObjectSpan(ctx, "StatObject", fileName)
+	res, err := svc.mc.StatObject(spanCtx, svc.bucketName, fileName, minio.StatObjectOptions{})
+	tracing.End(span, err)
+
 	if err != nil {
-		return nil, errors.Wrap(errors.WithStack(err), "error on get object")
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on get object")
 	}
 
 	return &res.LastModified, nil
 }
 
-func NewService(mc *minio.Client, bucketName string) Service {
+// ListFiles lists objects of the bucket, so files uploaded before their metadata was stored are included too
+func (svc *service) ListFiles(ctx context.Context) ([]*Entity, error) {
+	res := make([]*Entity, 0)
+
+	spanCtx, span := svc.startObjectSpan(ctx, "ListObjects", "")
+	defer span.End()
+
+	for obj := range svc.mc.ListObjects(spanCtx, svc.bucketName, minio.ListObjectsOptions{Recursive: true}) {
+		if obj.Err != nil {
+			span.RecordError(obj.Err)
+			span.SetStatus(codes.Error, obj.Err.Error())
+
+			return nil, requestid.Wrap(ctx, errors.WithStack(obj.Err), "error on list objects")
+		}
+
+		res = append(res, &Entity{
+			Name:        obj.Key,
+			ContentType: obj.ContentType,
+			Size:        obj.Size,
+			CreatedAt:   obj.LastModified,
+		})
+	}
+
+	return res, nil
+}
+
+func (svc *service) DeleteFile(ctx context.Context, fileName string) error {
+	// files uploaded before their metadata was stored have no entity
+	entity, err := svc.repo.FindByName(ctx, fileName)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find file by name")
+	}
+
+	spanCtx, span := svc.startObjectSpan(ctx, "RemoveObject", fileName)
+	err = svc.mc.RemoveObject(spanCtx, svc.bucketName, fileName, minio.RemoveObjectOptions{})
+	tracing.End(span, err)
+
+	if err != nil {
+		return requestid.Wrap(ctx, errors.WithStack(err), "error on remove object")
+	}
+
+	err = svc.repo.DeleteByName(ctx, fileName)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete file by name")
+	}
+
+	event := Event{
+		Action:    "file.delete",
+		FileName:  fileName,
+		Changes:   map[string]Change{},
+		CreatedAt: time.Now(),
+	}
+
+	if entity != nil {
+		event.Changes["ownerUUID"] = Change{From: entity.OwnerUUID, To: nil}
+		event.Changes["contentType"] = Change{From: entity.ContentType, To: nil}
+		event.Changes["size"] = Change{From: entity.Size, To: nil}
+	}
+
+	svc.audit(ctx, event)
+
+	return nil
+}
+
+func (svc *service) ReassignFiles(ctx context.Context, req ReassignFilesRequest) error {
+	err := svc.repo.UpdateOwner(ctx, req.FromUserUUID, req.ToUserUUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on update owner of files")
+	}
+
+	return nil
+}
+
+func (svc *service) CheckBucket(ctx context.Context) error {
+	spanCtx, span := svc.startObjectSpan(ctx, "BucketExists", "")
+	exists, err := svc.mc.BucketExists(spanCtx, svc.bucketName)
+	tracing.End(span, err)
+
+	if err != nil {
+		return requestid.Wrap(ctx, errors.WithStack(err), "error on check bucket exists")
+	}
+
+	if !exists {
+		return ErrBucketNotFound{Name: svc.bucketName}
+	}
+
+	return nil
+}
+
+func NewService(repo Repository, mc *minio.Client, bucketName string, roleQuotas map[string]Quota, allowedTypes []string, stripMetadata bool, auditor Auditor) Service {
 	svc := service{
-		mc:         mc,
-		bucketName: bucketName,
+		repo:          repo,
+		mc:            mc,
+		bucketName:    bucketName,
+		roleQuotas:    roleQuotas,
+		allowedTypes:  allowedTypes,
+		stripMetadata: stripMetadata,
+		auditor:       auditor,
 	}
 
 	return &svc
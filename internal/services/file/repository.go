@@ -0,0 +1,18 @@
+package file
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Insert(ctx context.Context, entity Entity) (err error)
+	// InsertWithinQuota returns the usage before the insert, ok is false when the file exceeds the quota
+	InsertWithinQuota(ctx context.Context, entity Entity, quota Quota) (res *Usage, ok bool, err error)
+	FindByName(ctx context.Context, name string) (res *Entity, err error)
+	DeleteByName(ctx context.Context, name string) (err error)
+	UpdateOwner(ctx context.Context, fromOwnerUUID, toOwnerUUID string) (err error)
+	GetUsageByOwner(ctx context.Context, ownerUUID string) (res *Usage, err error)
+	FindQuotaByOwner(ctx context.Context, ownerUUID string) (res *Quota, err error)
+	SaveQuota(ctx context.Context, ownerUUID string, quota Quota) (err error)
+	DeleteQuotaByOwner(ctx context.Context, ownerUUID string) (err error)
+}
@@ -7,11 +7,48 @@ import (
 )
 
 type Service interface {
-	UploadFile(ctx context.Context, r io.Reader) (res *UploadFileResponse, err error)
+	UploadFile(ctx context.Context, req UploadFileRequest) (res *Entity, err error)
+	GetFileByName(ctx context.Context, fileName string) (res *Entity, err error)
 	DownloadFile(ctx context.Context, filename string) (res io.ReadSeeker, err error)
 	GetFileLastModified(ctx context.Context, filename string) (res *time.Time, err error)
+	ListFiles(ctx context.Context) (res []*Entity, err error)
+	DeleteFile(ctx context.Context, fileName string) (err error)
+	ReassignFiles(ctx context.Context, req ReassignFilesRequest) (err error)
+	GetStorageUsage(ctx context.Context, req GetStorageUsageRequest) (res *GetStorageUsageResponse, err error)
+	SetStorageQuota(ctx context.Context, req SetStorageQuotaRequest) (err error)
+	DeleteStorageQuota(ctx context.Context, userUUID string) (err error)
+	CheckBucket(ctx context.Context) (err error)
 }
 
-type UploadFileResponse struct {
-	FileName string
+type UploadFileRequest struct {
+	UserUUID    string
+	UserRole    string
+	ContentType string
+	Reader      io.Reader
+	IP          string
+	UserAgent   string
+}
+
+type Auditor interface {
+	AuditFileAction(ctx context.Context, event Event)
+}
+
+type ReassignFilesRequest struct {
+	FromUserUUID string
+	ToUserUUID   string
+}
+
+type GetStorageUsageRequest struct {
+	UserUUID string
+	UserRole string
+}
+
+type GetStorageUsageResponse struct {
+	Usage Usage
+	Quota Quota
+}
+
+type SetStorageQuotaRequest struct {
+	UserUUID string
+	Quota    Quota
 }
@@ -0,0 +1,17 @@
+package file
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (svc *service) startObjectSpan(ctx context.Context, operation, objectName string) (context.Context, trace.Span) {
+	attrs := []label.KeyValue{label.String("object_store.bucket", svc.bucketName)}
+	if objectName != "" {
+		attrs = append(attrs, label.String("object_store.object", objectName))
+	}
+
+	return tracing.Start(ctx, "minio."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,98 @@
+package gc
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+type service struct {
+	postSvc post.Service
+	fileSvc file.Service
+	userSvc user.Service
+}
+
+func isReferenced(posts []*post.Entity, avatars map[string]bool, fileName string) bool {
+	if avatars[fileName] {
+		return true
+	}
+
+	for _, p := range posts {
+		if p.CoverImage != nil && p.CoverImage.FileName == fileName {
+			return true
+		}
+
+		for i := range p.Attachments {
+			if p.Attachments[i].FileName == fileName {
+				return true
+			}
+		}
+
+		if strings.Contains(p.ContentMarkdown, fileName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (svc *service) CollectOrphanedFiles(ctx context.Context, req CollectOrphanedFilesRequest) (*CollectOrphanedFilesResponse, error) {
+	posts, err := svc.postSvc.ListPosts(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list posts")
+	}
+
+	avatarFileNames, err := svc.userSvc.ListAvatarFileNames(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list avatar file names")
+	}
+
+	avatars := make(map[string]bool, len(avatarFileNames))
+	for i := range avatarFileNames {
+		avatars[avatarFileNames[i]] = true
+	}
+
+	files, err := svc.fileSvc.ListFiles(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list files")
+	}
+
+	rsp := CollectOrphanedFilesResponse{
+		Files: make([]*file.Entity, 0),
+	}
+
+	threshold := time.Now().Add(-req.GracePeriod)
+
+	for _, f := range files {
+		if f.CreatedAt.After(threshold) || isReferenced(posts, avatars, f.Name) {
+			continue
+		}
+
+		if !req.DryRun {
+			err = svc.fileSvc.DeleteFile(ctx, f.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error on delete file '%s'", f.Name)
+			}
+		}
+
+		rsp.Files = append(rsp.Files, f)
+		rsp.ReclaimedBytes += f.Size
+	}
+
+	return &rsp, nil
+}
+
+func NewService(postSvc post.Service, fileSvc file.Service, userSvc user.Service) Service {
+	svc := service{
+		postSvc: postSvc,
+		fileSvc: fileSvc,
+		userSvc: userSvc,
+	}
+
+	return &svc
+}
@@ -0,0 +1,22 @@
+package gc
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"time"
+)
+
+type Service interface {
+	CollectOrphanedFiles(ctx context.Context, req CollectOrphanedFilesRequest) (res *CollectOrphanedFilesResponse, err error)
+}
+
+type CollectOrphanedFilesRequest struct {
+	// GracePeriod keeps files newer than it, so uploads which are not attached to a post yet survive
+	GracePeriod time.Duration
+	DryRun      bool
+}
+
+type CollectOrphanedFilesResponse struct {
+	Files          []*file.Entity
+	ReclaimedBytes int64
+}
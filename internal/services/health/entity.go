@@ -0,0 +1,16 @@
+package health
+
+import "time"
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+type CheckResult struct {
+	Status   Status
+	Error    string
+	Duration time.Duration
+}
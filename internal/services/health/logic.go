@@ -0,0 +1,93 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type service struct {
+	checks       map[string]Check
+	timeout      time.Duration
+	shuttingDown int32
+}
+
+func (svc *service) CheckReadiness(ctx context.Context) (*CheckReadinessResponse, error) {
+	if atomic.LoadInt32(&svc.shuttingDown) == 1 {
+		return &CheckReadinessResponse{Ready: false, ShuttingDown: true, Checks: map[string]CheckResult{}}, nil
+	}
+
+	res := CheckReadinessResponse{
+		Ready:  true,
+		Checks: make(map[string]CheckResult, len(svc.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range svc.checks {
+		wg.Add(1)
+
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			result := svc.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			res.Checks[name] = result
+			if result.Status != StatusUp {
+				res.Ready = false
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return &res, nil
+}
+
+func (svc *service) run(ctx context.Context, check Check) CheckResult {
+	if svc.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, svc.timeout)
+
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	// checks which ignore the context still time out
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return CheckResult{Status: StatusDown, Error: err.Error(), Duration: time.Since(start)}
+	}
+
+	return CheckResult{Status: StatusUp, Duration: time.Since(start)}
+}
+
+func (svc *service) SetShuttingDown() {
+	atomic.StoreInt32(&svc.shuttingDown, 1)
+}
+
+func NewService(checks map[string]Check, timeout time.Duration) Service {
+	svc := service{
+		checks:  checks,
+		timeout: timeout,
+	}
+
+	return &svc
+}
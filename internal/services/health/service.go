@@ -0,0 +1,18 @@
+package health
+
+import (
+	"context"
+)
+
+type Check func(ctx context.Context) error
+
+type Service interface {
+	CheckReadiness(ctx context.Context) (res *CheckReadinessResponse, err error)
+	SetShuttingDown()
+}
+
+type CheckReadinessResponse struct {
+	Ready        bool
+	ShuttingDown bool
+	Checks       map[string]CheckResult
+}
@@ -4,9 +4,40 @@ import "time"
 
 type Entity struct {
 	UUID            string
+	AuthorUUID      string
 	Title           string
 	Slug            string
 	ContentMarkdown string
 	ContentHTML     string
 	PublishedAt     *time.Time
+	CoverImage      *Media
+	Attachments     []Media
+}
+
+type Media struct {
+	FileName   string
+	AltText    string
+	Caption    string
+	FocalPoint *FocalPoint
+}
+
+type Event struct {
+	ActorUUID string
+	Action    string
+	PostUUID  string
+	IP        string
+	UserAgent string
+	Changes   map[string]Change
+	CreatedAt time.Time
+}
+
+type Change struct {
+	From interface{}
+	To   interface{}
+}
+
+// FocalPoint is the relative position of the important part of an image, both values are between 0 and 1
+type FocalPoint struct {
+	X float64
+	Y float64
 }
@@ -25,3 +25,19 @@ type ErrPostWithSlugNotPublished struct {
 func (err ErrPostWithSlugNotPublished) Error() string {
 	return fmt.Sprintf("post with slug '%s' not published", err.Slug)
 }
+
+type ErrFileNotAllowed struct {
+	FileName string
+}
+
+func (err ErrFileNotAllowed) Error() string {
+	return fmt.Sprintf("file '%s' is not allowed to be used", err.FileName)
+}
+
+type ErrInvalidFocalPoint struct {
+	FileName string
+}
+
+func (err ErrInvalidFocalPoint) Error() string {
+	return fmt.Sprintf("focal point of file '%s' must be between 0 and 1", err.FileName)
+}
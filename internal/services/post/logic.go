@@ -7,18 +7,97 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
-	"github.com/pkg/errors"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"reflect"
 	"time"
 )
 
 type service struct {
-	repo Repository
+	repo    Repository
+	fileSvc file.Service
+	auditor Auditor
 }
 
-func (svc *service) PublishPostByUUID(ctx context.Context, postUUID string) (*Entity, error) {
+func (svc *service) audit(ctx context.Context, event Event) {
+	if svc.auditor == nil {
+		return
+	}
+
+	svc.auditor.AuditPostAction(ctx, event)
+}
+
+func changes(previous *Entity, entity Entity) map[string]Change {
+	var from Entity
+	if previous != nil {
+		from = *previous
+	}
+
+	// posts without attachments have nil or empty attachments
+	if from.Attachments == nil {
+		from.Attachments = []Media{}
+	}
+
+	if entity.Attachments == nil {
+		entity.Attachments = []Media{}
+	}
+
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"title", from.Title, entity.Title},
+		{"slug", from.Slug, entity.Slug},
+		{"contentMarkdown", from.ContentMarkdown, entity.ContentMarkdown},
+		{"coverImage", from.CoverImage, entity.CoverImage},
+		{"attachments", from.Attachments, entity.Attachments},
+	}
+
+	res := make(map[string]Change)
+
+	for _, f := range fields {
+		if previous != nil && reflect.DeepEqual(f.from, f.to) {
+			continue
+		}
+
+		if previous == nil {
+			f.from = nil
+		}
+
+		res[f.name] = Change{From: f.from, To: f.to}
+	}
+
+	return res
+}
+
+func (svc *service) validateMedia(ctx context.Context, userUUID string, coverImage *Media, attachments []Media) error {
+	media := attachments
+	if coverImage != nil {
+		media = append([]Media{*coverImage}, attachments...)
+	}
+
+	for i := range media {
+		if fp := media[i].FocalPoint; fp != nil && (fp.X < 0 || fp.X > 1 || fp.Y < 0 || fp.Y > 1) {
+			return ErrInvalidFocalPoint{FileName: media[i].FileName}
+		}
+
+		f, err := svc.fileSvc.GetFileByName(ctx, media[i].FileName)
+		if err != nil {
+			return requestid.Wrap(ctx, err, "error on get file by name")
+		}
+
+		if f.OwnerUUID != userUUID {
+			return ErrFileNotAllowed{FileName: media[i].FileName}
+		}
+	}
+
+	return nil
+}
+
+func (svc *service) PublishPostByUUID(ctx context.Context, postUUID string, req PublishPostByUUIDRequest) (*Entity, error) {
 	entity, err := svc.repo.FindByUUID(ctx, postUUID)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find post by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on find post by uuid")
 	}
 
 	if entity == nil {
@@ -31,8 +110,18 @@ func (svc *service) PublishPostByUUID(ctx context.Context, postUUID string) (*En
 
 		err = svc.repo.UpdateByUUID(ctx, postUUID, *entity)
 		if err != nil {
-			return nil, errors.Wrap(err, "error on update post by uuid")
+			return nil, requestid.Wrap(ctx, err, "error on update post by uuid")
 		}
+
+		svc.audit(ctx, Event{
+			ActorUUID: req.UserUUID,
+			Action:    "post.publish",
+			PostUUID:  entity.UUID,
+			IP:        req.IP,
+			UserAgent: req.UserAgent,
+			Changes:   map[string]Change{"publishedAt": {From: nil, To: now}},
+			CreatedAt: now,
+		})
 	}
 
 	return entity, nil
@@ -41,13 +130,18 @@ func (svc *service) PublishPostByUUID(ctx context.Context, postUUID string) (*En
 func (svc *service) UpdatePostByUUID(ctx context.Context, postUUID string, req UpdatePostByUUIDRequest) (*Entity, error) {
 	entity, err := svc.repo.FindByUUID(ctx, postUUID)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find post by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on find post by uuid")
 	}
 
 	if entity == nil {
 		return nil, ErrPostWithUUIDNotFound{UUID: postUUID}
 	}
 
+	err = svc.validateMedia(ctx, req.UserUUID, req.CoverImage, req.Attachments)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on validate media")
+	}
+
 	if req.Slug == "" {
 		req.Slug = req.Title
 	}
@@ -59,7 +153,7 @@ func (svc *service) UpdatePostByUUID(ctx context.Context, postUUID string, req U
 	for {
 		entity, err := svc.repo.FindBySlug(ctx, uniqueSlug)
 		if err != nil {
-			return nil, errors.Wrap(err, "error on find post by slug")
+			return nil, requestid.Wrap(ctx, err, "error on find post by slug")
 		}
 
 		if entity == nil || entity.UUID == postUUID {
@@ -73,14 +167,30 @@ func (svc *service) UpdatePostByUUID(ctx context.Context, postUUID string, req U
 
 	contentHTML := string(markdown.ToHTML([]byte(req.ContentMarkdown), parser.New(), nil))
 
+	previous := *entity
+
 	entity.Title = req.Title
 	entity.Slug = req.Slug
 	entity.ContentMarkdown = req.ContentMarkdown
 	entity.ContentHTML = contentHTML
+	entity.CoverImage = req.CoverImage
+	entity.Attachments = req.Attachments
 
 	err = svc.repo.UpdateByUUID(ctx, postUUID, *entity)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on update post by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on update post by uuid")
+	}
+
+	if diff := changes(&previous, *entity); len(diff) > 0 {
+		svc.audit(ctx, Event{
+			ActorUUID: req.UserUUID,
+			Action:    "post.update",
+			PostUUID:  entity.UUID,
+			IP:        req.IP,
+			UserAgent: req.UserAgent,
+			Changes:   diff,
+			CreatedAt: time.Now(),
+		})
 	}
 
 	return entity, nil
@@ -89,7 +199,7 @@ func (svc *service) UpdatePostByUUID(ctx context.Context, postUUID string, req U
 func (svc *service) GetPostByUUID(ctx context.Context, postUUID string) (*Entity, error) {
 	entity, err := svc.repo.FindByUUID(ctx, postUUID)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find post by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on find post by uuid")
 	}
 
 	if entity == nil {
@@ -102,7 +212,7 @@ func (svc *service) GetPostByUUID(ctx context.Context, postUUID string) (*Entity
 func (svc *service) GetPublishedPostBySlug(ctx context.Context, slug string) (*Entity, error) {
 	entity, err := svc.repo.FindBySlug(ctx, slug)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find post by slug")
+		return nil, requestid.Wrap(ctx, err, "error on find post by slug")
 	}
 
 	if entity == nil {
@@ -119,7 +229,7 @@ func (svc *service) GetPublishedPostBySlug(ctx context.Context, slug string) (*E
 func (svc *service) ListPosts(ctx context.Context) ([]*Entity, error) {
 	res, err := svc.repo.List(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on list posts")
+		return nil, requestid.Wrap(ctx, err, "error on list posts")
 	}
 
 	return res, nil
@@ -128,13 +238,18 @@ func (svc *service) ListPosts(ctx context.Context) ([]*Entity, error) {
 func (svc *service) ListPublishedPosts(ctx context.Context) ([]*Entity, error) {
 	res, err := svc.repo.ListPublished(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on list published posts")
+		return nil, requestid.Wrap(ctx, err, "error on list published posts")
 	}
 
 	return res, nil
 }
 
 func (svc *service) CreatePost(ctx context.Context, req CreatePostRequest) (*Entity, error) {
+	err := svc.validateMedia(ctx, req.UserUUID, req.CoverImage, req.Attachments)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on validate media")
+	}
+
 	if req.Slug == "" {
 		req.Slug = req.Title
 	}
@@ -146,7 +261,7 @@ func (svc *service) CreatePost(ctx context.Context, req CreatePostRequest) (*Ent
 	for {
 		entity, err := svc.repo.FindBySlug(ctx, uniqueSlug)
 		if err != nil {
-			return nil, errors.Wrap(err, "error on find post by slug")
+			return nil, requestid.Wrap(ctx, err, "error on find post by slug")
 		}
 
 		if entity == nil {
@@ -162,22 +277,48 @@ func (svc *service) CreatePost(ctx context.Context, req CreatePostRequest) (*Ent
 
 	entity := Entity{
 		UUID:            uuid.New().String(),
+		AuthorUUID:      req.UserUUID,
 		Title:           req.Title,
 		Slug:            req.Slug,
 		ContentMarkdown: req.ContentMarkdown,
 		ContentHTML:     contentHTML,
+		CoverImage:      req.CoverImage,
+		Attachments:     req.Attachments,
 	}
 
-	err := svc.repo.Insert(ctx, entity)
+	err = svc.repo.Insert(ctx, entity)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on insert post")
+		return nil, requestid.Wrap(ctx, err, "error on insert post")
 	}
 
+	svc.audit(ctx, Event{
+		ActorUUID: req.UserUUID,
+		Action:    "post.create",
+		PostUUID:  entity.UUID,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		Changes:   changes(nil, entity),
+		CreatedAt: time.Now(),
+	})
+
 	return &entity, nil
 }
 
-func NewService(repo Repository) Service {
-	svc := service{repo: repo}
+func (svc *service) ReassignPosts(ctx context.Context, req ReassignPostsRequest) error {
+	err := svc.repo.UpdateAuthor(ctx, req.FromUserUUID, req.ToUserUUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on update author of posts")
+	}
+
+	return nil
+}
+
+func NewService(repo Repository, fileSvc file.Service, auditor Auditor) Service {
+	svc := service{
+		repo:    repo,
+		fileSvc: fileSvc,
+		auditor: auditor,
+	}
 
 	return &svc
 }
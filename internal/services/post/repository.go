@@ -11,4 +11,5 @@ type Repository interface {
 	List(ctx context.Context) (res []*Entity, err error)
 	UpdateByUUID(ctx context.Context, uuid string, entity Entity) (err error)
 	ListPublished(ctx context.Context) (res []*Entity, err error)
+	UpdateAuthor(ctx context.Context, fromUserUUID, toUserUUID string) (err error)
 }
@@ -10,18 +10,44 @@ type Service interface {
 	GetPublishedPostBySlug(ctx context.Context, slug string) (res *Entity, err error)
 	ListPosts(ctx context.Context) (res []*Entity, err error)
 	UpdatePostByUUID(ctx context.Context, postUUID string, req UpdatePostByUUIDRequest) (res *Entity, err error)
-	PublishPostByUUID(ctx context.Context, postUUID string) (res *Entity, err error)
+	PublishPostByUUID(ctx context.Context, postUUID string, req PublishPostByUUIDRequest) (res *Entity, err error)
 	ListPublishedPosts(ctx context.Context) (res []*Entity, err error)
+	ReassignPosts(ctx context.Context, req ReassignPostsRequest) (err error)
 }
 
 type CreatePostRequest struct {
+	UserUUID        string
 	Title           string
 	Slug            string
 	ContentMarkdown string
+	CoverImage      *Media
+	Attachments     []Media
+	IP              string
+	UserAgent       string
 }
 
 type UpdatePostByUUIDRequest struct {
+	UserUUID        string
 	Title           string
 	Slug            string
 	ContentMarkdown string
+	CoverImage      *Media
+	Attachments     []Media
+	IP              string
+	UserAgent       string
+}
+
+type PublishPostByUUIDRequest struct {
+	UserUUID  string
+	IP        string
+	UserAgent string
+}
+
+type Auditor interface {
+	AuditPostAction(ctx context.Context, event Event)
+}
+
+type ReassignPostsRequest struct {
+	FromUserUUID string
+	ToUserUUID   string
 }
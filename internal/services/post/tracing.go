@@ -0,0 +1,86 @@
+package post
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracingService struct {
+	next Service
+}
+
+func (svc *tracingService) CreatePost(ctx context.Context, req CreatePostRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.CreatePost", trace.WithAttributes(label.String("post.slug", req.Slug)))
+	res, err := svc.next.CreatePost(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GetPostByUUID(ctx context.Context, postUUID string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.GetPostByUUID", trace.WithAttributes(label.String("post.uuid", postUUID)))
+	res, err := svc.next.GetPostByUUID(ctx, postUUID)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GetPublishedPostBySlug(ctx context.Context, slug string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.GetPublishedPostBySlug", trace.WithAttributes(label.String("post.slug", slug)))
+	res, err := svc.next.GetPublishedPostBySlug(ctx, slug)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListPosts(ctx context.Context) ([]*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.ListPosts")
+	res, err := svc.next.ListPosts(ctx)
+	span.SetAttributes(label.Int("post.count", len(res)))
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) UpdatePostByUUID(ctx context.Context, postUUID string, req UpdatePostByUUIDRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.UpdatePostByUUID", trace.WithAttributes(label.String("post.uuid", postUUID)))
+	res, err := svc.next.UpdatePostByUUID(ctx, postUUID, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) PublishPostByUUID(ctx context.Context, postUUID string, req PublishPostByUUIDRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.PublishPostByUUID", trace.WithAttributes(label.String("post.uuid", postUUID)))
+	res, err := svc.next.PublishPostByUUID(ctx, postUUID, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListPublishedPosts(ctx context.Context) ([]*Entity, error) {
+	ctx, span := tracing.Start(ctx, "post.ListPublishedPosts")
+	res, err := svc.next.ListPublishedPosts(ctx)
+	span.SetAttributes(label.Int("post.count", len(res)))
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ReassignPosts(ctx context.Context, req ReassignPostsRequest) error {
+	ctx, span := tracing.Start(ctx, "post.ReassignPosts", trace.WithAttributes(label.String("user.uuid", req.FromUserUUID)))
+	err := svc.next.ReassignPosts(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func NewTracingService(next Service) Service {
+	svc := tracingService{
+		next: next,
+	}
+
+	return &svc
+}
@@ -0,0 +1,14 @@
+package ratelimit
+
+import "time"
+
+type Policy struct {
+	Limit  int64
+	Window time.Duration
+}
+
+type Counter struct {
+	Key         string
+	WindowStart time.Time
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,11 @@
+package ratelimit
+
+import "fmt"
+
+type ErrInvalidPolicy struct {
+	Policy Policy
+}
+
+func (err ErrInvalidPolicy) Error() string {
+	return fmt.Sprintf("invalid policy of %d requests per %s", err.Policy.Limit, err.Policy.Window)
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"time"
+)
+
+type service struct {
+	repo Repository
+}
+
+func (svc *service) Allow(ctx context.Context, req AllowRequest) (*AllowResponse, error) {
+	if req.Policy.Limit <= 0 || req.Policy.Window <= 0 {
+		return nil, ErrInvalidPolicy{Policy: req.Policy}
+	}
+
+	now := time.Now()
+	windowStart := now.Truncate(req.Policy.Window)
+	windowEnd := windowStart.Add(req.Policy.Window)
+
+	count, err := svc.repo.Increment(ctx, Counter{
+		Key:         req.Key,
+		WindowStart: windowStart,
+		ExpiresAt:   windowEnd,
+	})
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on increment counter")
+	}
+
+	remaining := req.Policy.Limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	res := AllowResponse{
+		Allowed:   count <= req.Policy.Limit,
+		Limit:     req.Policy.Limit,
+		Remaining: remaining,
+		Reset:     windowEnd.Sub(now),
+	}
+
+	return &res, nil
+}
+
+func (svc *service) DeleteExpiredCounters(ctx context.Context) error {
+	err := svc.repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete expired counters")
+	}
+
+	return nil
+}
+
+func NewService(repo Repository) Service {
+	svc := service{
+		repo: repo,
+	}
+
+	return &svc
+}
@@ -0,0 +1,12 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Increment restarts the count from 1 when the window differs from the stored one
+	Increment(ctx context.Context, counter Counter) (count int64, err error)
+	DeleteExpired(ctx context.Context, now time.Time) (err error)
+}
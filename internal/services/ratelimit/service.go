@@ -0,0 +1,23 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Service interface {
+	Allow(ctx context.Context, req AllowRequest) (res *AllowResponse, err error)
+	DeleteExpiredCounters(ctx context.Context) (err error)
+}
+
+type AllowRequest struct {
+	Key    string
+	Policy Policy
+}
+
+type AllowResponse struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	Reset     time.Duration
+}
@@ -0,0 +1,282 @@
+package user
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"strings"
+	"time"
+)
+
+func (svc *service) admin(ctx context.Context, actorUUID string) (*Entity, error) {
+	actor, err := svc.repo.FindByUUID(ctx, actorUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by uuid")
+	}
+
+	if actor == nil || actor.Role != RoleAdmin || actor.Disabled() {
+		return nil, ErrPermissionDenied{}
+	}
+
+	return actor, nil
+}
+
+func (svc *service) managedUser(ctx context.Context, actor *Entity, userUUID string) (*Entity, error) {
+	if actor.UUID == userUUID {
+		return nil, ErrCannotManageSelf{}
+	}
+
+	return svc.GetUserByUUID(ctx, userUUID)
+}
+
+func (svc *service) disable(ctx context.Context, entity *Entity, now time.Time) error {
+	entity.DisabledAt = now
+
+	err := svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on update user by uuid")
+	}
+
+	err = svc.sessionRepo.RevokeByUserUUID(ctx, entity.UUID, "", now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on revoke sessions by user uuid")
+	}
+
+	return nil
+}
+
+func (svc *service) auditAdminAction(ctx context.Context, event AdminEvent) {
+	if svc.auditor == nil {
+		return
+	}
+
+	svc.auditor.AuditAdminAction(ctx, event)
+}
+
+func (svc *service) ListUsers(ctx context.Context, req ListUsersRequest) ([]*Entity, error) {
+	_, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := svc.repo.Search(ctx, strings.TrimSpace(req.Query))
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on search users")
+	}
+
+	return res, nil
+}
+
+func (svc *service) SetUserRole(ctx context.Context, req SetUserRoleRequest) (*Entity, error) {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Role.Valid() {
+		return nil, ErrInvalidRole{Role: req.Role}
+	}
+
+	entity, err := svc.managedUser(ctx, actor, req.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entity.Role == req.Role {
+		return entity, nil
+	}
+
+	previous := entity.Role
+	entity.Role = req.Role
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update user by uuid")
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "user.set_role",
+		TargetType: "user",
+		TargetID:   entity.UUID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    map[string]Change{"role": {From: previous, To: entity.Role}},
+		CreatedAt:  time.Now(),
+	})
+
+	return entity, nil
+}
+
+// PromoteToAdmin has no actor, operators run it to create the first admin
+func (svc *service) PromoteToAdmin(ctx context.Context, username string) (*Entity, error) {
+	entity, err := svc.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if entity.Role == RoleAdmin {
+		return entity, nil
+	}
+
+	previous := entity.Role
+	entity.Role = RoleAdmin
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update user by uuid")
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		Action:     "user.set_role",
+		TargetType: "user",
+		TargetID:   entity.UUID,
+		Changes:    map[string]Change{"role": {From: previous, To: entity.Role}},
+		CreatedAt:  time.Now(),
+	})
+
+	return entity, nil
+}
+
+func (svc *service) DisableUser(ctx context.Context, req DisableUserRequest) (*Entity, error) {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := svc.managedUser(ctx, actor, req.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entity.Disabled() {
+		return entity, nil
+	}
+
+	now := time.Now()
+
+	err = svc.disable(ctx, entity, now)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "user.disable",
+		TargetType: "user",
+		TargetID:   entity.UUID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    map[string]Change{"disabledAt": {From: nil, To: now}},
+		CreatedAt:  now,
+	})
+
+	return entity, nil
+}
+
+func (svc *service) EnableUser(ctx context.Context, req EnableUserRequest) (*Entity, error) {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := svc.managedUser(ctx, actor, req.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entity.Disabled() {
+		return entity, nil
+	}
+
+	previous := entity.DisabledAt
+	entity.DisabledAt = time.Time{}
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update user by uuid")
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "user.enable",
+		TargetType: "user",
+		TargetID:   entity.UUID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    map[string]Change{"disabledAt": {From: previous, To: nil}},
+		CreatedAt:  time.Now(),
+	})
+
+	return entity, nil
+}
+
+// DeleteUser disables the user first, so a failed deletion leaves it unusable and can be repeated
+func (svc *service) DeleteUser(ctx context.Context, req DeleteUserRequest) error {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return err
+	}
+
+	entity, err := svc.managedUser(ctx, actor, req.UserUUID)
+	if err != nil {
+		return err
+	}
+
+	if req.ReassignTo != "" {
+		if req.ReassignTo == entity.UUID {
+			return ErrInvalidReassignTarget{UUID: req.ReassignTo}
+		}
+
+		_, err = svc.GetUserByUUID(ctx, req.ReassignTo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !entity.Disabled() {
+		err = svc.disable(ctx, entity, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = svc.postSvc.ReassignPosts(ctx, post.ReassignPostsRequest{FromUserUUID: entity.UUID, ToUserUUID: req.ReassignTo})
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on reassign posts")
+	}
+
+	err = svc.fileSvc.ReassignFiles(ctx, file.ReassignFilesRequest{FromUserUUID: entity.UUID, ToUserUUID: req.ReassignTo})
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on reassign files")
+	}
+
+	err = svc.repo.DeleteByUUID(ctx, entity.UUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete user by uuid")
+	}
+
+	changes := map[string]Change{
+		"username": {From: entity.Username, To: nil},
+		"email":    {From: entity.Email, To: nil},
+		"role":     {From: entity.Role, To: nil},
+	}
+
+	if req.ReassignTo != "" {
+		changes["reassignedTo"] = Change{From: nil, To: req.ReassignTo}
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "user.delete",
+		TargetType: "user",
+		TargetID:   entity.UUID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    changes,
+		CreatedAt:  time.Now(),
+	})
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// APITokenPrefix tells api tokens apart from access tokens, and makes leaked tokens easy to find by secret scanners
+	APITokenPrefix             = "api_"
+	apiTokenSize               = 32
+	apiTokenLastUsedResolution = time.Minute
+	maxAPITokenNameLength      = 64
+)
+
+func (svc *service) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPITokenNameLength {
+		return nil, ErrInvalidAPITokenName{}
+	}
+
+	scopes := make([]Scope, 0, len(req.Scopes))
+	seen := make(map[Scope]bool, len(req.Scopes))
+
+	for _, scope := range req.Scopes {
+		if !scope.Valid() {
+			return nil, ErrInvalidScope{Scope: scope}
+		}
+
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope{}
+	}
+
+	now := time.Now()
+
+	if !req.ExpiresAt.IsZero() && !req.ExpiresAt.After(now) {
+		return nil, ErrInvalidAPITokenExpiry{}
+	}
+
+	if _, err := svc.GetUserByUUID(ctx, req.UserUUID); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, apiTokenSize)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on read random bytes")
+	}
+
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	apiToken := APIToken{
+		UUID:      uuid.New().String(),
+		UserUUID:  req.UserUUID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	err = svc.apiTokenRepo.Insert(ctx, apiToken)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on insert api token")
+	}
+
+	return &CreateAPITokenResponse{Token: token, APIToken: apiToken}, nil
+}
+
+func (svc *service) ListAPITokens(ctx context.Context, userUUID string) ([]APIToken, error) {
+	res, err := svc.apiTokenRepo.ListByUserUUID(ctx, userUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list api tokens by user uuid")
+	}
+
+	return res, nil
+}
+
+func (svc *service) RevokeAPIToken(ctx context.Context, req RevokeAPITokenRequest) error {
+	ok, err := svc.apiTokenRepo.Revoke(ctx, req.TokenUUID, req.UserUUID, time.Now())
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on revoke api token")
+	}
+
+	// tokens of other users aren't found, so their uuids aren't revealed
+	if !ok {
+		return ErrAPITokenNotFound{UUID: req.TokenUUID}
+	}
+
+	return nil
+}
+
+func (svc *service) VerifyAPIToken(ctx context.Context, tokenString string) (*APIToken, error) {
+	if !strings.HasPrefix(tokenString, APITokenPrefix) {
+		return nil, ErrInvalidAPIToken{}
+	}
+
+	apiToken, err := svc.apiTokenRepo.FindByTokenHash(ctx, hashToken(tokenString))
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find api token by token hash")
+	}
+
+	now := time.Now()
+
+	if apiToken == nil || apiToken.Revoked() || apiToken.Expired(now) {
+		return nil, ErrInvalidAPIToken{}
+	}
+
+	entity, err := svc.repo.FindByUUID(ctx, apiToken.UserUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find user by uuid")
+	}
+
+	if entity == nil {
+		return nil, ErrInvalidAPIToken{}
+	}
+
+	if entity.Disabled() {
+		return nil, ErrUserDisabled{}
+	}
+
+	if now.Sub(apiToken.LastUsedAt) >= apiTokenLastUsedResolution {
+		err = svc.apiTokenRepo.UpdateLastUsedAt(ctx, apiToken.UUID, now)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on update last used at of api token")
+		}
+
+		apiToken.LastUsedAt = now
+	}
+
+	return apiToken, nil
+}
@@ -0,0 +1,345 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/pkg/errors"
+	netmail "net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+)
+
+var usernameRegexp = regexp.MustCompile(fmt.Sprintf(`^[a-zA-Z0-9_.-]{%d,%d}$`, minUsernameLength, maxUsernameLength))
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail{Email: email}
+	}
+
+	return email, nil
+}
+
+func validateUsername(username string) error {
+	if !usernameRegexp.MatchString(username) {
+		return ErrInvalidUsername{Username: username}
+	}
+
+	return nil
+}
+
+func (svc *service) checkEmailAvailable(ctx context.Context, email, userUUID string) error {
+	entity, err := svc.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find by email")
+	}
+
+	if entity != nil && entity.UUID != userUUID {
+		return ErrEmailAlreadyUsed{Email: email}
+	}
+
+	return nil
+}
+
+func (svc *service) InviteUser(ctx context.Context, req InviteUserRequest) error {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return err
+	}
+
+	if !req.Role.Valid() {
+		return ErrInvalidRole{Role: req.Role}
+	}
+
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return err
+	}
+
+	err = svc.checkEmailAvailable(ctx, email, "")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invitation := Invitation{
+		ID:          uuid.New().String(),
+		InviterUUID: actor.UUID,
+		Email:       email,
+		Role:        req.Role,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(svc.invitationTTL),
+	}
+
+	// the invitation is stored by the id of the token, so the token can be accepted once
+	err = svc.invitationRepo.Insert(ctx, invitation)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on insert invitation")
+	}
+
+	token, err := svc.signToken(ctx, tokenClaims{
+		ID:      invitation.ID,
+		Subject: actor.UUID,
+		Purpose: purposeInvitation,
+		Email:   email,
+		Role:    req.Role,
+	}, svc.invitationTTL)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on sign invitation token")
+	}
+
+	link, err := tokenLink(svc.invitationURL, token)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on make invitation link")
+	}
+
+	err = svc.mailer.Send(ctx, mail.Message{
+		To:      []string{email},
+		Subject: "You are invited",
+		Text: fmt.Sprintf("Hi,\n\n%s invited you to join as %s. Open the link below to choose a username and a password. "+
+			"It expires in %s.\n\n%s\n", actor.Username, req.Role, formatDuration(svc.invitationTTL), link),
+	})
+	if errors.Is(err, mail.ErrQueueFull{}) {
+		return err
+	}
+
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on send invitation mail")
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "user.invite",
+		TargetType: "invitation",
+		TargetID:   email,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    map[string]Change{"email": {From: nil, To: email}, "role": {From: nil, To: req.Role}},
+		CreatedAt:  now,
+	})
+
+	return nil
+}
+
+func (svc *service) AcceptInvitation(ctx context.Context, req AcceptInvitationRequest) (*Entity, error) {
+	now := time.Now()
+
+	claims, err := svc.verifyToken(ctx, req.Token, purposeInvitation)
+	if err != nil || !claims.Role.Valid() {
+		return nil, ErrInvalidInvitationToken{}
+	}
+
+	invitation, err := svc.invitationRepo.FindByID(ctx, claims.ID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find invitation by id")
+	}
+
+	if invitation == nil || !invitation.UsedAt.IsZero() || now.After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitationToken{}
+	}
+
+	// invitations of disabled, deleted or demoted admins can't be accepted
+	inviter, err := svc.repo.FindByUUID(ctx, invitation.InviterUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by uuid")
+	}
+
+	if inviter == nil || inviter.Role != RoleAdmin || inviter.Disabled() {
+		return nil, ErrInvalidInvitationToken{}
+	}
+
+	err = validateUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.checkEmailAvailable(ctx, invitation.Email, "")
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := svc.repo.FindByUsername(ctx, req.Username)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by username")
+	}
+
+	if existing != nil {
+		return nil, ErrUsernameAlreadyUsed{Username: req.Username}
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on hash password")
+	}
+
+	ok, err := svc.invitationRepo.MarkUsed(ctx, invitation.ID, now)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on mark invitation used")
+	}
+
+	if !ok {
+		return nil, ErrInvalidInvitationToken{}
+	}
+
+	entity := Entity{
+		UUID:            uuid.New().String(),
+		Username:        req.Username,
+		Email:           invitation.Email,
+		EmailVerifiedAt: now,
+		PasswordHash:    passwordHash,
+		Role:            invitation.Role,
+	}
+
+	err = svc.repo.Insert(ctx, entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on insert user")
+	}
+
+	return &entity, nil
+}
+
+func (svc *service) UpdateEmail(ctx context.Context, req UpdateEmailRequest) (*Entity, error) {
+	entity, err := svc.GetUserByUUID(ctx, req.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if email == entity.Email {
+		return entity, nil
+	}
+
+	err = svc.checkEmailAvailable(ctx, email, entity.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(email, entity.Email) {
+		entity.EmailVerifiedAt = time.Time{}
+	}
+
+	entity.Email = email
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update by uuid")
+	}
+
+	if !entity.EmailVerified() {
+		err = svc.sendVerificationMail(ctx, entity)
+		if errors.Is(err, mail.ErrQueueFull{}) {
+			return nil, err
+		}
+
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on send verification mail")
+		}
+	}
+
+	return entity, nil
+}
+
+func (svc *service) RequestEmailVerification(ctx context.Context, userUUID string) error {
+	entity, err := svc.GetUserByUUID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+
+	if entity.Email == "" {
+		return ErrInvalidEmail{Email: entity.Email}
+	}
+
+	if entity.EmailVerified() {
+		return nil
+	}
+
+	err = svc.sendVerificationMail(ctx, entity)
+	if errors.Is(err, mail.ErrQueueFull{}) {
+		return err
+	}
+
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on send verification mail")
+	}
+
+	return nil
+}
+
+// sendVerificationMail puts the email in the token, so links sent to previous emails can't verify it
+func (svc *service) sendVerificationMail(ctx context.Context, entity *Entity) error {
+	token, err := svc.signToken(ctx, tokenClaims{
+		ID:      uuid.New().String(),
+		Subject: entity.UUID,
+		Purpose: purposeEmailVerification,
+		Email:   entity.Email,
+	}, svc.verificationTTL)
+	if err != nil {
+		return errors.Wrap(err, "error on sign email verification token")
+	}
+
+	link, err := tokenLink(svc.verificationURL, token)
+	if err != nil {
+		return errors.Wrap(err, "error on make email verification link")
+	}
+
+	err = svc.mailer.Send(ctx, mail.Message{
+		To:      []string{entity.Email},
+		Subject: "Verify your email",
+		Text: fmt.Sprintf("Hi %s,\n\nOpen the link below to verify your email. It expires in %s.\n\n%s\n\n"+
+			"If you didn't set this email, you can ignore it.\n", entity.Username, formatDuration(svc.verificationTTL), link),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error on send mail")
+	}
+
+	return nil
+}
+
+func (svc *service) VerifyEmail(ctx context.Context, req VerifyEmailRequest) (*Entity, error) {
+	claims, err := svc.verifyToken(ctx, req.Token, purposeEmailVerification)
+	if err != nil {
+		return nil, ErrInvalidEmailVerificationToken{}
+	}
+
+	entity, err := svc.repo.FindByUUID(ctx, claims.Subject)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by uuid")
+	}
+
+	if entity == nil || !strings.EqualFold(entity.Email, claims.Email) {
+		return nil, ErrInvalidEmailVerificationToken{}
+	}
+
+	if entity.EmailVerified() {
+		return entity, nil
+	}
+
+	entity.EmailVerifiedAt = time.Now()
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update by uuid")
+	}
+
+	return entity, nil
+}
@@ -1,7 +1,215 @@
 package user
 
+import "time"
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleAuthor Role = "author"
+)
+
+var Roles = []Role{RoleAdmin, RoleAuthor}
+
+func (r Role) Valid() bool {
+	for i := range Roles {
+		if Roles[i] == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scope is a permission of api tokens, sessions have all permissions
+type Scope string
+
+const (
+	ScopePostsRead  Scope = "posts:read"
+	ScopePostsWrite Scope = "posts:write"
+	ScopeFilesRead  Scope = "files:read"
+	ScopeFilesWrite Scope = "files:write"
+)
+
+var Scopes = []Scope{ScopePostsRead, ScopePostsWrite, ScopeFilesRead, ScopeFilesWrite}
+
+func (s Scope) Valid() bool {
+	for i := range Scopes {
+		if Scopes[i] == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Entity struct {
-	UUID         string
-	Username     string
-	PasswordHash string
+	UUID            string
+	Username        string
+	Email           string
+	EmailVerifiedAt time.Time
+	PasswordHash    string
+	Role            Role
+	Profile         Profile
+	DisabledAt      time.Time
+}
+
+type Profile struct {
+	DisplayName    string
+	Bio            string
+	AvatarFileName string
+	Website        string
+	SocialLinks    []string
+}
+
+func (e Entity) EmailVerified() bool {
+	return e.Email != "" && !e.EmailVerifiedAt.IsZero()
+}
+
+func (e Entity) Disabled() bool {
+	return !e.DisabledAt.IsZero()
+}
+
+type Session struct {
+	ID        string
+	UserUUID  string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	RevokedAt time.Time
+}
+
+func (s Session) Revoked() bool {
+	return !s.RevokedAt.IsZero()
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserUUID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+type Invitation struct {
+	ID          string
+	InviterUUID string
+	Email       string
+	Role        Role
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	UsedAt      time.Time
+}
+
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// LockoutPolicy zero values disable backoff or lockout
+type LockoutPolicy struct {
+	BackoffAfter    int
+	BaseDelay       time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	ResetAfter      time.Duration
+}
+
+type LoginEvent struct {
+	UserUUID  string
+	Username  string
+	IP        string
+	UserAgent string
+	Success   bool
+	TwoFactor bool
+	Provider  string
+	// Reason of the failure, it is never sent to clients
+	Reason    string
+	CreatedAt time.Time
+}
+
+type AdminEvent struct {
+	ActorUUID  string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	UserAgent  string
+	Changes    map[string]Change
+	CreatedAt  time.Time
+}
+
+type Change struct {
+	From interface{}
+	To   interface{}
+}
+
+type TwoFactor struct {
+	UserUUID           string
+	Secret             string
+	RecoveryCodeHashes []string
+	// LastUsedStep is the time step of the last accepted code, so a code can't be replayed
+	LastUsedStep int64
+	CreatedAt    time.Time
+	EnabledAt    time.Time
+}
+
+func (tf TwoFactor) Enabled() bool {
+	return !tf.EnabledAt.IsZero()
+}
+
+type Identity struct {
+	Provider  string
+	Subject   string
+	UserUUID  string
+	CreatedAt time.Time
+}
+
+type SigningKey struct {
+	ID string
+	// PrivateKey is PEM encoded PKCS #1
+	PrivateKey []byte
+	CreatedAt  time.Time
+	PromotedAt time.Time
+	RetiresAt  time.Time
+}
+
+func (k SigningKey) Promoted() bool {
+	return !k.PromotedAt.IsZero()
+}
+
+func (k SigningKey) Retired(now time.Time) bool {
+	return !k.RetiresAt.IsZero() && !now.Before(k.RetiresAt)
+}
+
+type APIToken struct {
+	UUID       string
+	UserUUID   string
+	Name       string
+	TokenHash  string
+	Scopes     []Scope
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+func (t APIToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+func (t APIToken) Revoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+func (t APIToken) HasScope(scope Scope) bool {
+	for i := range t.Scopes {
+		if t.Scopes[i] == scope {
+			return true
+		}
+	}
+
+	return false
 }
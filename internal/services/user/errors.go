@@ -1,6 +1,235 @@
 package user
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type ErrUserWithUUIDNotFound struct {
+	UUID string
+}
+
+func (err ErrUserWithUUIDNotFound) Error() string {
+	return fmt.Sprintf("user with uuid '%s' not found", err.UUID)
+}
+
+// ErrInvalidCredentials is returned for both unknown usernames and wrong passwords, so usernames can't be enumerated
+type ErrInvalidCredentials struct {
+}
+
+func (err ErrInvalidCredentials) Error() string {
+	return "invalid credentials"
+}
+
+type ErrLoginThrottled struct {
+	RetryAfter time.Duration
+}
+
+func (err ErrLoginThrottled) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %d seconds", int(math.Ceil(err.RetryAfter.Seconds())))
+}
+
+type ErrPermissionDenied struct {
+}
+
+func (err ErrPermissionDenied) Error() string {
+	return "permission denied"
+}
+
+type ErrInvalidRole struct {
+	Role Role
+}
+
+func (err ErrInvalidRole) Error() string {
+	return fmt.Sprintf("invalid role '%s'", err.Role)
+}
+
+type ErrInvalidChallengeToken struct {
+}
+
+func (err ErrInvalidChallengeToken) Error() string {
+	return "invalid or expired challenge token"
+}
+
+type ErrInvalidTwoFactorCode struct {
+}
+
+func (err ErrInvalidTwoFactorCode) Error() string {
+	return "invalid two-factor code"
+}
+
+type ErrTwoFactorAlreadyEnabled struct {
+}
+
+func (err ErrTwoFactorAlreadyEnabled) Error() string {
+	return "two-factor authentication is already enabled"
+}
+
+type ErrTwoFactorNotEnrolled struct {
+}
+
+func (err ErrTwoFactorNotEnrolled) Error() string {
+	return "two-factor authentication is not enrolled"
+}
+
+type ErrTwoFactorRequired struct {
+	Role Role
+}
+
+func (err ErrTwoFactorRequired) Error() string {
+	return fmt.Sprintf("two-factor authentication is required for role '%s'", err.Role)
+}
+
+type ErrPasswordTooShort struct {
+	MinLength int
+}
+
+func (err ErrPasswordTooShort) Error() string {
+	return fmt.Sprintf("password must be at least %d characters", err.MinLength)
+}
+
+type ErrPasswordTooLong struct {
+	MaxBytes int
+}
+
+func (err ErrPasswordTooLong) Error() string {
+	return fmt.Sprintf("password must be at most %d bytes", err.MaxBytes)
+}
+
+type ErrInvalidPasswordResetToken struct {
+}
+
+func (err ErrInvalidPasswordResetToken) Error() string {
+	return "invalid or expired password reset token"
+}
+
+type ErrInvalidEmail struct {
+	Email string
+}
+
+func (err ErrInvalidEmail) Error() string {
+	return fmt.Sprintf("invalid email '%s'", err.Email)
+}
+
+type ErrEmailAlreadyUsed struct {
+	Email string
+}
+
+func (err ErrEmailAlreadyUsed) Error() string {
+	return fmt.Sprintf("email '%s' is already used", err.Email)
+}
+
+type ErrInvalidUsername struct {
+	Username string
+}
+
+func (err ErrInvalidUsername) Error() string {
+	return fmt.Sprintf("invalid username '%s', usernames have %d to %d letters, digits, '_', '.' or '-'", err.Username, minUsernameLength, maxUsernameLength)
+}
+
+type ErrUsernameAlreadyUsed struct {
+	Username string
+}
+
+func (err ErrUsernameAlreadyUsed) Error() string {
+	return fmt.Sprintf("username '%s' is already used", err.Username)
+}
+
+type ErrInvalidInvitationToken struct {
+}
+
+func (err ErrInvalidInvitationToken) Error() string {
+	return "invalid or expired invitation token"
+}
+
+type ErrInvalidEmailVerificationToken struct {
+}
+
+func (err ErrInvalidEmailVerificationToken) Error() string {
+	return "invalid or expired email verification token"
+}
+
+type ErrEmailNotVerified struct {
+}
+
+func (err ErrEmailNotVerified) Error() string {
+	return "email is not verified"
+}
+
+type ErrOIDCUserNotFound struct {
+	Provider string
+}
+
+func (err ErrOIDCUserNotFound) Error() string {
+	return fmt.Sprintf("no user is linked to the '%s' account", err.Provider)
+}
+
+type ErrSigningKeyNotFound struct {
+	KeyID string
+}
+
+func (err ErrSigningKeyNotFound) Error() string {
+	return fmt.Sprintf("signing key '%s' not found", err.KeyID)
+}
+
+type ErrSigningKeyRetired struct {
+	KeyID string
+}
+
+func (err ErrSigningKeyRetired) Error() string {
+	return fmt.Sprintf("signing key '%s' is retired", err.KeyID)
+}
+
+type ErrOverlapTooShort struct {
+	Overlap        time.Duration
+	AccessTokenTTL time.Duration
+}
+
+func (err ErrOverlapTooShort) Error() string {
+	return fmt.Sprintf("overlap %s is shorter than access token lifetime %s", err.Overlap, err.AccessTokenTTL)
+}
+
+type ErrInvalidAPITokenName struct {
+}
+
+func (err ErrInvalidAPITokenName) Error() string {
+	return fmt.Sprintf("api token name should have 1 to %d characters", maxAPITokenNameLength)
+}
+
+type ErrInvalidScope struct {
+	Scope Scope
+}
+
+func (err ErrInvalidScope) Error() string {
+	if err.Scope == "" {
+		return "at least one scope is required"
+	}
+
+	return fmt.Sprintf("invalid scope '%s'", err.Scope)
+}
+
+type ErrInvalidAPITokenExpiry struct {
+}
+
+func (err ErrInvalidAPITokenExpiry) Error() string {
+	return "api token expiry should be in the future"
+}
+
+type ErrAPITokenNotFound struct {
+	UUID string
+}
+
+func (err ErrAPITokenNotFound) Error() string {
+	return fmt.Sprintf("api token with uuid '%s' not found", err.UUID)
+}
+
+type ErrInvalidAPIToken struct {
+}
+
+func (err ErrInvalidAPIToken) Error() string {
+	return "invalid api token"
+}
 
 type ErrUserWithUsernameNotFound struct {
 	Username string
@@ -10,17 +239,70 @@ func (err ErrUserWithUsernameNotFound) Error() string {
 	return fmt.Sprintf("user with username '%s' not found", err.Username)
 }
 
-type ErrUserWithUUIDNotFound struct {
-	UUID string
+type ErrInvalidDisplayName struct {
 }
 
-func (err ErrUserWithUUIDNotFound) Error() string {
-	return fmt.Sprintf("user with uuid '%s' not found", err.UUID)
+func (err ErrInvalidDisplayName) Error() string {
+	return fmt.Sprintf("display name should have at most %d characters", maxDisplayNameLength)
+}
+
+type ErrInvalidBio struct {
+}
+
+func (err ErrInvalidBio) Error() string {
+	return fmt.Sprintf("bio should have at most %d characters", maxBioLength)
+}
+
+type ErrInvalidLink struct {
+	Link string
+}
+
+func (err ErrInvalidLink) Error() string {
+	return fmt.Sprintf("invalid link '%s', links should be http or https urls", err.Link)
+}
+
+type ErrTooManySocialLinks struct {
 }
 
-type ErrInvalidPasswordReceived struct {
+func (err ErrTooManySocialLinks) Error() string {
+	return fmt.Sprintf("at most %d social links are allowed", maxSocialLinks)
+}
+
+type ErrFileNotAllowed struct {
+	FileName string
+}
+
+func (err ErrFileNotAllowed) Error() string {
+	return fmt.Sprintf("file '%s' is not allowed to be used", err.FileName)
+}
+
+type ErrAvatarNotImage struct {
+	FileName string
+}
+
+func (err ErrAvatarNotImage) Error() string {
+	return fmt.Sprintf("avatar file '%s' is not an image", err.FileName)
+}
+
+type ErrUserDisabled struct {
+}
+
+func (err ErrUserDisabled) Error() string {
+	return "user is disabled"
+}
+
+// ErrCannotManageSelf keeps the last admin from locking everyone out
+type ErrCannotManageSelf struct {
+}
+
+func (err ErrCannotManageSelf) Error() string {
+	return "admins can't change their own role, disable or delete themselves"
+}
+
+type ErrInvalidReassignTarget struct {
+	UUID string
 }
 
-func (err ErrInvalidPasswordReceived) Error() string {
-	return fmt.Sprintf("invalid password received")
+func (err ErrInvalidReassignTarget) Error() string {
+	return fmt.Sprintf("posts and files can't be reassigned to the deleted user '%s'", err.UUID)
 }
@@ -0,0 +1,407 @@
+package user
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/nasermirzaei89/api/internal/jwk"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/jwt"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+const (
+	signingKeyBits = 2048
+	keyRingTTL     = time.Minute
+	// keyRingRefreshInterval limits reloads for unknown key ids, so forged key ids can't load keys on each request
+	keyRingRefreshInterval = 10 * time.Second
+)
+
+type ringKey struct {
+	SigningKey
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+// keyID is the RFC 7638 thumbprint of the public key
+func keyID(pub *rsa.PublicKey) string {
+	return jwk.NewRSAKey("", pub).Thumbprint()
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid pem")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on parse pkcs1 private key")
+	}
+
+	return key, nil
+}
+
+func parsePublicKey(b []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid pem")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on parse pkix public key")
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an rsa key")
+	}
+
+	return pub, nil
+}
+
+func newConfigKey(signKey, verificationKey []byte) (*ringKey, error) {
+	if len(signKey) == 0 && len(verificationKey) == 0 {
+		return nil, nil
+	}
+
+	var key ringKey
+
+	if len(signKey) > 0 {
+		private, err := parsePrivateKey(signKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error on parse sign key")
+		}
+
+		key.private = private
+		key.public = &private.PublicKey
+	}
+
+	if len(verificationKey) > 0 {
+		pub, err := parsePublicKey(verificationKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error on parse verification key")
+		}
+
+		if key.public != nil && (key.public.N.Cmp(pub.N) != 0 || key.public.E != pub.E) {
+			return nil, errors.New("sign key and verification key are not a key pair")
+		}
+
+		key.public = pub
+	}
+
+	key.ID = keyID(key.public)
+
+	return &key, nil
+}
+
+func (svc *service) signingKeys(ctx context.Context, refresh bool) ([]ringKey, error) {
+	svc.keysMu.Lock()
+	defer svc.keysMu.Unlock()
+
+	age := time.Since(svc.keysLoadedAt)
+	if svc.keys != nil && age < keyRingTTL && (!refresh || age < keyRingRefreshInterval) {
+		return svc.keys, nil
+	}
+
+	keys, err := svc.keyRepo.List(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on list signing keys")
+	}
+
+	res := make([]ringKey, 0, len(keys))
+
+	for i := range keys {
+		if len(keys[i].PrivateKey) == 0 {
+			if svc.configKey != nil && keys[i].ID == svc.configKey.ID {
+				res = append(res, ringKey{SigningKey: keys[i], public: svc.configKey.public})
+			}
+
+			continue
+		}
+
+		private, err := parsePrivateKey(keys[i].PrivateKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error on parse signing key '%s'", keys[i].ID)
+		}
+
+		res = append(res, ringKey{SigningKey: keys[i], private: private, public: &private.PublicKey})
+	}
+
+	svc.keys = res
+	svc.keysLoadedAt = time.Now()
+
+	return res, nil
+}
+
+func (svc *service) expireSigningKeys() {
+	svc.keysMu.Lock()
+	defer svc.keysMu.Unlock()
+
+	svc.keysLoadedAt = time.Time{}
+}
+
+func (svc *service) signingKey(ctx context.Context) (*ringKey, error) {
+	keys, err := svc.signingKeys(ctx, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on get signing keys")
+	}
+
+	now := time.Now()
+
+	var res *ringKey
+
+	for i := range keys {
+		if keys[i].private != nil && keys[i].Promoted() && !keys[i].Retired(now) && (res == nil || keys[i].PromotedAt.After(res.PromotedAt)) {
+			res = &keys[i]
+		}
+	}
+
+	if res == nil && svc.configKey != nil && svc.configKey.private != nil {
+		res = svc.configKey
+	}
+
+	if res == nil {
+		return nil, errors.New("no signing key, generate and promote a key")
+	}
+
+	return res, nil
+}
+
+func (svc *service) verificationKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	if svc.configKey != nil && keyID == "" {
+		keyID = svc.configKey.ID
+	}
+
+	for _, refresh := range []bool{false, true} {
+		keys, err := svc.signingKeys(ctx, refresh)
+		if err != nil {
+			return nil, errors.Wrap(err, "error on get signing keys")
+		}
+
+		for i := range keys {
+			if keys[i].ID != keyID {
+				continue
+			}
+
+			if keys[i].Retired(time.Now()) {
+				return nil, errors.Errorf("key '%s' is retired", keyID)
+			}
+
+			return keys[i].public, nil
+		}
+
+		if svc.configKey != nil && keyID == svc.configKey.ID {
+			return svc.configKey.public, nil
+		}
+	}
+
+	return nil, errors.Errorf("key '%s' not found", keyID)
+}
+
+func findRingKey(keys []ringKey, id string) *ringKey {
+	for i := range keys {
+		if keys[i].ID == id {
+			return &keys[i]
+		}
+	}
+
+	return nil
+}
+
+func signJWT(token jwt.Token, key *ringKey) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": string(jwt.RS256), "typ": "JWT", "kid": key.ID})
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on marshal header")
+	}
+
+	payload, err := json.Marshal(token.GetPayload())
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on marshal payload")
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hashed := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key.private, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on sign")
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (svc *service) verifyJWT(ctx context.Context, tokenString string) (jwt.Token, error) {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode header")
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+
+	err = json.Unmarshal(b, &header)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on unmarshal header")
+	}
+
+	// the algorithm isn't taken from the token, so public keys can't be used as hmac secrets
+	if header.Algorithm != string(jwt.RS256) {
+		return nil, errors.Errorf("unsupported algorithm '%s'", header.Algorithm)
+	}
+
+	key, err := svc.verificationKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on get verification key")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode signature")
+	}
+
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on verify signature")
+	}
+
+	token, err := jwt.Parse(tokenString)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on parse jwt token")
+	}
+
+	return token, nil
+}
+
+func (svc *service) GetJWKS(ctx context.Context) (*jwk.Set, error) {
+	keys, err := svc.signingKeys(ctx, false)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on get signing keys")
+	}
+
+	res := jwk.Set{Keys: make([]jwk.Key, 0, len(keys)+1)}
+
+	if svc.configKey != nil && findRingKey(keys, svc.configKey.ID) == nil {
+		res.Keys = append(res.Keys, jwk.NewRSAKey(svc.configKey.ID, svc.configKey.public))
+	}
+
+	now := time.Now()
+
+	for i := range keys {
+		if !keys[i].Retired(now) {
+			res.Keys = append(res.Keys, jwk.NewRSAKey(keys[i].ID, keys[i].public))
+		}
+	}
+
+	return &res, nil
+}
+
+func (svc *service) GenerateSigningKey(ctx context.Context) (*SigningKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, errors.WithStack(err), "error on generate rsa key")
+	}
+
+	key := SigningKey{
+		ID:         keyID(&private.PublicKey),
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}),
+		CreatedAt:  time.Now(),
+	}
+
+	err = svc.keyRepo.Insert(ctx, key)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on insert signing key")
+	}
+
+	svc.expireSigningKeys()
+
+	key.PrivateKey = nil
+
+	return &key, nil
+}
+
+func (svc *service) PromoteSigningKey(ctx context.Context, req PromoteSigningKeyRequest) error {
+	keys, err := svc.keyRepo.List(ctx)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on list signing keys")
+	}
+
+	now := time.Now()
+
+	var key *SigningKey
+
+	configKeyAdded := false
+
+	for i := range keys {
+		if keys[i].ID == req.KeyID {
+			key = &keys[i]
+		}
+
+		if svc.configKey != nil && keys[i].ID == svc.configKey.ID {
+			configKeyAdded = true
+		}
+	}
+
+	// the key of the config has no private key in the repository, so it can't be promoted
+	if key == nil || len(key.PrivateKey) == 0 {
+		return ErrSigningKeyNotFound{KeyID: req.KeyID}
+	}
+
+	if key.Retired(now) {
+		return ErrSigningKeyRetired{KeyID: req.KeyID}
+	}
+
+	if req.Overlap < svc.accessTokenTTL {
+		return ErrOverlapTooShort{Overlap: req.Overlap, AccessTokenTTL: svc.accessTokenTTL}
+	}
+
+	err = svc.keyRepo.Promote(ctx, req.KeyID, now, now.Add(req.Overlap))
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on promote signing key")
+	}
+
+	// the key of the config retires at the first promotion like previous keys, after tokens it signed have expired
+	if svc.configKey != nil && !configKeyAdded {
+		err = svc.keyRepo.Insert(ctx, SigningKey{ID: svc.configKey.ID, PrivateKey: []byte{}, CreatedAt: now, RetiresAt: now.Add(req.Overlap)})
+		if err != nil {
+			svc.expireSigningKeys()
+
+			return requestid.Wrap(ctx, err, "error on insert config signing key")
+		}
+	}
+
+	svc.expireSigningKeys()
+
+	return nil
+}
+
+func (svc *service) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	keys, err := svc.keyRepo.List(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list signing keys")
+	}
+
+	for i := range keys {
+		keys[i].PrivateKey = nil
+	}
+
+	return keys, nil
+}
@@ -3,50 +3,245 @@ package user
 import (
 	"context"
 	"github.com/google/uuid"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
 	"github.com/nasermirzaei89/jwt"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	claimPurpose              = "purpose"
+	claimEmail                = "email"
+	claimRole                 = "role"
+	purposeTwoFactorChallenge = "two_factor_challenge"
+	purposeInvitation         = "invitation"
+	purposeEmailVerification  = "email_verification"
+	challengeTTL              = 5 * time.Minute
+)
+
 type service struct {
-	repo            Repository
-	signKey         []byte
-	verificationKey []byte
+	repo             Repository
+	attemptRepo      LoginAttemptRepository
+	twoFactorRepo    TwoFactorRepository
+	requirementRepo  TwoFactorRequirementRepository
+	sessionRepo      SessionRepository
+	resetTokenRepo   PasswordResetTokenRepository
+	invitationRepo   InvitationRepository
+	identityRepo     IdentityRepository
+	keyRepo          SigningKeyRepository
+	apiTokenRepo     APITokenRepository
+	fileSvc          file.Service
+	postSvc          post.Service
+	mailer           mail.Mailer
+	auditor          Auditor
+	accessTokenTTL   time.Duration
+	accountLockout   LockoutPolicy
+	ipLockout        LockoutPolicy
+	totpIssuer       string
+	passwordResetURL string
+	passwordResetTTL time.Duration
+	invitationURL    string
+	invitationTTL    time.Duration
+	verificationURL  string
+	verificationTTL  time.Duration
+	provisionRoles   map[string]Role
+	configKey        *ringKey
+	keys             []ringKey
+	keysLoadedAt     time.Time
+	keysMu           sync.Mutex
+	// dummyHash is compared when the user doesn't exist, so the response time doesn't reveal existence of usernames
+	dummyHash []byte
 }
 
-func (svc *service) GetUserByTokenString(ctx context.Context, tokenString string) (*Entity, error) {
-	err := jwt.Verify(tokenString, svc.verificationKey)
+type tokenClaims struct {
+	ID      string
+	Subject string
+	Purpose string
+	Email   string
+	Role    Role
+}
+
+func (svc *service) signToken(ctx context.Context, claims tokenClaims, ttl time.Duration) (string, error) {
+	now := time.Now()
+
+	token := jwt.New(jwt.RS256)
+	token.SetSubject(claims.Subject)
+	token.SetIssuedAt(now)
+	token.SetJWTID(claims.ID)
+
+	if claims.Purpose != "" {
+		token.Set(claimPurpose, claims.Purpose)
+	}
+
+	if claims.Email != "" {
+		token.Set(claimEmail, claims.Email)
+	}
+
+	if claims.Role != "" {
+		token.Set(claimRole, string(claims.Role))
+	}
+
+	if ttl > 0 {
+		token.SetExpirationTime(now.Add(ttl))
+	}
+
+	key, err := svc.signingKey(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "error on get signing key")
+	}
+
+	tokenString, err := signJWT(token, key)
+	if err != nil {
+		return "", errors.Wrap(err, "error on sign token")
+	}
+
+	return tokenString, nil
+}
+
+// verifyToken checks the purpose, so a challenge token can't be used as an access token
+func (svc *service) verifyToken(ctx context.Context, tokenString, purpose string) (*tokenClaims, error) {
+	token, err := svc.verifyJWT(ctx, tokenString)
 	if err != nil {
 		return nil, errors.Wrap(err, "error on verify jwt token")
 	}
 
-	token, err := jwt.Parse(tokenString)
+	var claims tokenClaims
+
+	v, _ := token.Get(claimPurpose)
+	if claims.Purpose, _ = v.(string); claims.Purpose != purpose {
+		return nil, errors.New("unexpected token purpose")
+	}
+
+	// claims are parsed as float64, so the expiration time is checked here
+	if v, errExp := token.Get(jwt.ClaimExpirationTime); errExp == nil {
+		exp, ok := v.(float64)
+		if !ok || time.Now().After(time.Unix(int64(exp), 0)) {
+			return nil, errors.New("token expired")
+		}
+	}
+
+	claims.ID, err = token.GetJWTID()
 	if err != nil {
-		return nil, errors.Wrap(err, "error on parse jwt token")
+		return nil, errors.Wrap(err, "error on get token id")
 	}
 
-	subject, err := token.GetSubject()
+	claims.Subject, err = token.GetSubject()
 	if err != nil {
 		return nil, errors.Wrap(err, "error on get token subject")
 	}
 
+	v, _ = token.Get(claimEmail)
+	claims.Email, _ = v.(string)
+
+	v, _ = token.Get(claimRole)
+	role, _ := v.(string)
+	claims.Role = Role(role)
+
+	return &claims, nil
+}
+
+func tokenLink(page, token string) (string, error) {
+	link, err := url.Parse(page)
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on parse url")
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+func (svc *service) issueAccessToken(ctx context.Context, entity *Entity, ip, userAgent string) (string, error) {
+	session := Session{
+		ID:        uuid.New().String(),
+		UserUUID:  entity.UUID,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now(),
+	}
+
+	err := svc.sessionRepo.Insert(ctx, session)
+	if err != nil {
+		return "", errors.Wrap(err, "error on insert session")
+	}
+
+	accessToken, err := svc.signToken(ctx, tokenClaims{ID: session.ID, Subject: entity.UUID}, svc.accessTokenTTL)
+	if err != nil {
+		return "", errors.Wrap(err, "error on sign token")
+	}
+
+	return accessToken, nil
+}
+
+func (svc *service) verifyAccessToken(ctx context.Context, tokenString string) (*Session, error) {
+	claims, err := svc.verifyToken(ctx, tokenString, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "error on verify token")
+	}
+
+	session, err := svc.sessionRepo.FindByID(ctx, claims.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on find session by id")
+	}
+
+	if session == nil || session.UserUUID != claims.Subject || session.Revoked() {
+		return nil, errors.New("session is revoked")
+	}
+
+	return session, nil
+}
+
+func (svc *service) GetUserByTokenString(ctx context.Context, tokenString string) (*Entity, error) {
+	session, err := svc.verifyAccessToken(ctx, tokenString)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on verify access token")
+	}
+
+	subject := session.UserUUID
+
 	entity, err := svc.repo.FindByUUID(ctx, subject)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find user by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on find user by uuid")
 	}
 
 	if entity == nil {
 		return nil, ErrUserWithUUIDNotFound{UUID: subject}
 	}
 
+	if entity.Disabled() {
+		return nil, ErrUserDisabled{}
+	}
+
 	return entity, nil
 }
 
+func (svc *service) LogOut(ctx context.Context, accessToken string) error {
+	session, err := svc.verifyAccessToken(ctx, accessToken)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on verify access token")
+	}
+
+	err = svc.sessionRepo.RevokeByID(ctx, session.ID, time.Now())
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on revoke session by id")
+	}
+
+	return nil
+}
+
 func (svc *service) GetUserByUUID(ctx context.Context, userUUID string) (*Entity, error) {
 	entity, err := svc.repo.FindByUUID(ctx, userUUID)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find by uuid")
+		return nil, requestid.Wrap(ctx, err, "error on find by uuid")
 	}
 
 	if entity == nil {
@@ -56,48 +251,265 @@ func (svc *service) GetUserByUUID(ctx context.Context, userUUID string) (*Entity
 	return entity, nil
 }
 
+func lockoutDelay(policy LockoutPolicy, failures int) time.Duration {
+	if policy.LockoutAfter > 0 && failures >= policy.LockoutAfter {
+		return policy.LockoutDuration
+	}
+
+	if policy.BackoffAfter > 0 && failures >= policy.BackoffAfter {
+		// cap the shift, so the delay doesn't overflow
+		shift := failures - policy.BackoffAfter
+		if shift > 30 {
+			shift = 30
+		}
+
+		delay := policy.BaseDelay << uint(shift)
+		if policy.LockoutDuration > 0 && delay > policy.LockoutDuration {
+			delay = policy.LockoutDuration
+		}
+
+		return delay
+	}
+
+	return 0
+}
+
+func (svc *service) attemptKeys(req LogInRequest) map[string]LockoutPolicy {
+	res := map[string]LockoutPolicy{
+		"account:" + strings.ToLower(req.Username): svc.accountLockout,
+	}
+
+	if req.IP != "" {
+		res["ip:"+req.IP] = svc.ipLockout
+	}
+
+	return res
+}
+
+func (svc *service) lockedFor(ctx context.Context, key string, now time.Time) (time.Duration, error) {
+	attempts, err := svc.attemptRepo.FindByKey(ctx, key)
+	if err != nil {
+		return 0, errors.Wrap(err, "error on find login attempts by key")
+	}
+
+	if attempts == nil || !attempts.LockedUntil.After(now) {
+		return 0, nil
+	}
+
+	return attempts.LockedUntil.Sub(now), nil
+}
+
+// recordFailure counts atomically, so concurrent failures can't escape the lockout
+func (svc *service) recordFailure(ctx context.Context, key string, policy LockoutPolicy, now time.Time) error {
+	// zero resetBefore keeps failures until a successful login
+	var resetBefore time.Time
+	if policy.ResetAfter > 0 {
+		resetBefore = now.Add(-policy.ResetAfter)
+	}
+
+	failures, err := svc.attemptRepo.Increment(ctx, key, now, resetBefore)
+	if err != nil {
+		return errors.Wrap(err, "error on increment login attempts")
+	}
+
+	if delay := lockoutDelay(policy, failures); delay > 0 {
+		err = svc.attemptRepo.Lock(ctx, key, now.Add(delay))
+		if err != nil {
+			return errors.Wrap(err, "error on lock login attempts")
+		}
+	}
+
+	return nil
+}
+
+func (svc *service) DeleteExpiredLoginAttempts(ctx context.Context) error {
+	if svc.accountLockout.ResetAfter <= 0 || svc.ipLockout.ResetAfter <= 0 {
+		return nil
+	}
+
+	resetAfter := svc.accountLockout.ResetAfter
+	if svc.ipLockout.ResetAfter > resetAfter {
+		resetAfter = svc.ipLockout.ResetAfter
+	}
+
+	now := time.Now()
+
+	err := svc.attemptRepo.DeleteExpired(ctx, now.Add(-resetAfter), now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete expired login attempts")
+	}
+
+	return nil
+}
+
+func (svc *service) audit(ctx context.Context, event LoginEvent) {
+	if svc.auditor == nil {
+		return
+	}
+
+	svc.auditor.AuditLogin(ctx, event)
+}
+
 func (svc *service) LogIn(ctx context.Context, req LogInRequest) (*LogInResponse, error) {
+	now := time.Now()
+	keys := svc.attemptKeys(req)
+
+	event := LoginEvent{
+		Username:  req.Username,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		CreatedAt: now,
+	}
+
+	// keys are locked whether the user exists or not, so a lockout doesn't reveal existence of usernames
+	for key := range keys {
+		retryAfter, err := svc.lockedFor(ctx, key, now)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on check login lock")
+		}
+
+		if retryAfter > 0 {
+			event.Reason = "throttled"
+			svc.audit(ctx, event)
+
+			return nil, ErrLoginThrottled{RetryAfter: retryAfter}
+		}
+	}
+
 	entity, err := svc.repo.FindByUsername(ctx, req.Username)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on find by username")
+		return nil, requestid.Wrap(ctx, err, "error on find by username")
 	}
 
-	if entity == nil {
-		return nil, ErrUserWithUsernameNotFound{Username: req.Username}
+	hash := svc.dummyHash
+	if entity != nil {
+		event.UserUUID = entity.UUID
+
+		// users provisioned by OpenID Connect providers have no password
+		if entity.PasswordHash != "" {
+			hash = []byte(entity.PasswordHash)
+		}
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(entity.PasswordHash), []byte(req.Password))
-	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return nil, ErrInvalidPasswordReceived{}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(req.Password))
+	if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, requestid.Wrap(ctx, err, "error on compare hash and password")
+	}
+
+	if entity == nil || entity.PasswordHash == "" || err != nil {
+		event.Reason = "invalid password"
+		if entity == nil {
+			event.Reason = "user not found"
 		}
 
-		return nil, errors.Wrap(err, "error on compare hash and password")
+		svc.audit(ctx, event)
+
+		for key, policy := range keys {
+			err = svc.recordFailure(ctx, key, policy, now)
+			if err != nil {
+				return nil, requestid.Wrap(ctx, err, "error on record login failure")
+			}
+		}
+
+		return nil, ErrInvalidCredentials{}
 	}
 
-	token := jwt.New(jwt.RS256)
-	token.SetSubject(entity.UUID)
-	token.SetIssuedAt(time.Now())
-	token.SetJWTID(uuid.New().String())
+	// only failures of the account are forgotten, so a valid account doesn't reset failures of the ip
+	err = svc.attemptRepo.DeleteByKey(ctx, "account:"+strings.ToLower(req.Username))
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on delete login attempts by key")
+	}
+
+	return svc.completeLogIn(ctx, entity, event)
+}
+
+func (svc *service) completeLogIn(ctx context.Context, entity *Entity, event LoginEvent) (*LogInResponse, error) {
+	if entity.Disabled() {
+		event.Reason = "user is disabled"
+		svc.audit(ctx, event)
 
-	accessToken, err := jwt.Sign(token, svc.signKey)
+		return nil, ErrUserDisabled{}
+	}
+
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, entity.UUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	required, err := svc.isTwoFactorRequired(ctx, entity.Role)
 	if err != nil {
-		return nil, errors.Wrap(err, "error on sign token")
+		return nil, requestid.Wrap(ctx, err, "error on check two factor requirement")
 	}
 
 	rsp := LogInResponse{
-		AccessToken: accessToken,
-		UserUUID:    entity.UUID,
+		UserUUID: entity.UUID,
+	}
+
+	if required || (twoFactor != nil && twoFactor.Enabled()) {
+		rsp.ChallengeToken, err = svc.signToken(ctx, tokenClaims{
+			ID:      uuid.New().String(),
+			Subject: entity.UUID,
+			Purpose: purposeTwoFactorChallenge,
+		}, challengeTTL)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on sign challenge token")
+		}
+
+		rsp.TwoFactorEnrollmentRequired = twoFactor == nil || !twoFactor.Enabled()
+
+		return &rsp, nil
 	}
 
+	rsp.AccessToken, err = svc.issueAccessToken(ctx, entity, event.IP, event.UserAgent)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on issue access token")
+	}
+
+	event.Success = true
+	svc.audit(ctx, event)
+
 	return &rsp, nil
 }
 
-func NewService(repo Repository, signKey, verificationKey []byte) Service {
+func NewService(repo Repository, config Config) Service {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	if err != nil {
+		panic(errors.Wrap(errors.WithStack(err), "error on generate dummy hash"))
+	}
+
+	configKey, err := newConfigKey(config.SignKey, config.VerificationKey)
+	if err != nil {
+		panic(errors.Wrap(err, "error on parse key pair"))
+	}
+
 	svc := service{
-		repo:            repo,
-		signKey:         signKey,
-		verificationKey: verificationKey,
+		repo:             repo,
+		attemptRepo:      config.LoginAttemptRepository,
+		twoFactorRepo:    config.TwoFactorRepository,
+		requirementRepo:  config.TwoFactorRequirementRepository,
+		sessionRepo:      config.SessionRepository,
+		resetTokenRepo:   config.PasswordResetTokenRepository,
+		invitationRepo:   config.InvitationRepository,
+		identityRepo:     config.IdentityRepository,
+		keyRepo:          config.SigningKeyRepository,
+		apiTokenRepo:     config.APITokenRepository,
+		fileSvc:          config.FileService,
+		postSvc:          config.PostService,
+		mailer:           config.Mailer,
+		auditor:          config.Auditor,
+		accessTokenTTL:   config.AccessTokenTTL,
+		accountLockout:   config.AccountLockout,
+		ipLockout:        config.IPLockout,
+		totpIssuer:       config.TOTPIssuer,
+		passwordResetURL: config.PasswordResetURL,
+		passwordResetTTL: config.PasswordResetTTL,
+		invitationURL:    config.InvitationURL,
+		invitationTTL:    config.InvitationTTL,
+		verificationURL:  config.EmailVerificationURL,
+		verificationTTL:  config.EmailVerificationTTL,
+		provisionRoles:   config.OIDCProvisionRoles,
+		configKey:        configKey,
+		dummyHash:        dummyHash,
 	}
 
 	return &svc
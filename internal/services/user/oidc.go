@@ -0,0 +1,149 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxUsernameBase = maxUsernameLength - 9
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func usernameBase(req LogInWithOIDCRequest) string {
+	name := req.PreferredUsername
+	if name == "" {
+		name = strings.SplitN(req.Email, "@", 2)[0]
+	}
+
+	name = usernameInvalidChars.ReplaceAllString(name, "")
+	if len(name) > maxUsernameBase {
+		name = name[:maxUsernameBase]
+	}
+
+	if len(name) < minUsernameLength {
+		name = "user"
+	}
+
+	return name
+}
+
+func (svc *service) availableUsername(ctx context.Context, base string) (string, error) {
+	for i := 1; i < 10; i++ {
+		username := base
+		if i > 1 {
+			username = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		existing, err := svc.repo.FindByUsername(ctx, username)
+		if err != nil {
+			return "", requestid.Wrap(ctx, err, "error on find by username")
+		}
+
+		if existing == nil {
+			return username, nil
+		}
+	}
+
+	return base + "-" + uuid.New().String()[:8], nil
+}
+
+func (svc *service) oidcUser(ctx context.Context, req LogInWithOIDCRequest) (*Entity, error) {
+	identity, err := svc.identityRepo.FindByProviderAndSubject(ctx, req.Provider, req.Subject)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find identity by provider and subject")
+	}
+
+	if identity != nil {
+		return svc.GetUserByUUID(ctx, identity.UserUUID)
+	}
+
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		email = ""
+	}
+
+	var entity *Entity
+
+	if email != "" {
+		entity, err = svc.repo.FindByEmail(ctx, email)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on find by email")
+		}
+	}
+
+	now := time.Now()
+
+	switch {
+	case entity != nil:
+		// only emails verified by both sides are linked, so someone setting an email of others can't take it over
+		if !req.EmailVerified || !entity.EmailVerified() {
+			return nil, ErrEmailAlreadyUsed{Email: email}
+		}
+	default:
+		role, ok := svc.provisionRoles[req.Provider]
+		if !ok {
+			return nil, ErrOIDCUserNotFound{Provider: req.Provider}
+		}
+
+		username, err := svc.availableUsername(ctx, usernameBase(req))
+		if err != nil {
+			return nil, err
+		}
+
+		entity = &Entity{
+			UUID:     uuid.New().String(),
+			Username: username,
+			Email:    email,
+			Role:     role,
+		}
+
+		if email != "" && req.EmailVerified {
+			entity.EmailVerifiedAt = now
+		}
+
+		err = svc.repo.Insert(ctx, *entity)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on insert user")
+		}
+	}
+
+	err = svc.identityRepo.Insert(ctx, Identity{
+		Provider:  req.Provider,
+		Subject:   req.Subject,
+		UserUUID:  entity.UUID,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on insert identity")
+	}
+
+	return entity, nil
+}
+
+func (svc *service) LogInWithOIDC(ctx context.Context, req LogInWithOIDCRequest) (*LogInResponse, error) {
+	event := LoginEvent{
+		Username:  req.PreferredUsername,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		Provider:  req.Provider,
+		CreatedAt: time.Now(),
+	}
+
+	entity, err := svc.oidcUser(ctx, req)
+	if err != nil {
+		event.Reason = err.Error()
+		svc.audit(ctx, event)
+
+		return nil, err
+	}
+
+	event.UserUUID = entity.UUID
+	event.Username = entity.Username
+
+	return svc.completeLogIn(ctx, entity, event)
+}
@@ -0,0 +1,264 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength is the maximum bytes bcrypt accepts
+	maxPasswordLength = 72
+	resetTokenSize    = 32
+)
+
+func validatePassword(password string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return ErrPasswordTooShort{MinLength: minPasswordLength}
+	}
+
+	if len(password) > maxPasswordLength {
+		return ErrPasswordTooLong{MaxBytes: maxPasswordLength}
+	}
+
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.Wrap(errors.WithStack(err), "error on generate hash from password")
+	}
+
+	return string(hash), nil
+}
+
+func comparePassword(hash, password string) (bool, error) {
+	if hash == "" {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(errors.WithStack(err), "error on compare hash and password")
+	}
+
+	return true, nil
+}
+
+func formatDuration(d time.Duration) string {
+	unit, name := time.Minute, "minute"
+	if d >= time.Hour && d%time.Hour == 0 {
+		unit, name = time.Hour, "hour"
+	}
+
+	n := int64(d / unit)
+	if n == 1 {
+		return fmt.Sprintf("1 %s", name)
+	}
+
+	return fmt.Sprintf("%d %ss", n, name)
+}
+
+// hashToken uses a fast hash, since tokens are random
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func (svc *service) ChangePassword(ctx context.Context, req ChangePasswordRequest) error {
+	session, err := svc.verifyAccessToken(ctx, req.AccessToken)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on verify access token")
+	}
+
+	entity, err := svc.GetUserByUUID(ctx, session.UserUUID)
+	if err != nil {
+		return err
+	}
+
+	// the current password is throttled like logins, so a stolen access token can't guess it
+	now := time.Now()
+	key := "account:" + strings.ToLower(entity.Username)
+
+	retryAfter, err := svc.lockedFor(ctx, key, now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on check login lock")
+	}
+
+	if retryAfter > 0 {
+		return ErrLoginThrottled{RetryAfter: retryAfter}
+	}
+
+	ok, err := comparePassword(entity.PasswordHash, req.CurrentPassword)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on compare password")
+	}
+
+	if !ok {
+		err = svc.recordFailure(ctx, key, svc.accountLockout, now)
+		if err != nil {
+			return requestid.Wrap(ctx, err, "error on record login failure")
+		}
+
+		return ErrInvalidCredentials{}
+	}
+
+	err = validatePassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	entity.PasswordHash, err = hashPassword(req.NewPassword)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on hash password")
+	}
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on update by uuid")
+	}
+
+	err = svc.sessionRepo.RevokeByUserUUID(ctx, entity.UUID, session.ID, now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on revoke sessions by user uuid")
+	}
+
+	return nil
+}
+
+func (svc *service) RequestPasswordReset(ctx context.Context, req RequestPasswordResetRequest) error {
+	email := strings.TrimSpace(req.Email)
+	if email == "" {
+		return nil
+	}
+
+	entity, err := svc.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find by email")
+	}
+
+	// links are only sent to verified emails, so an email set by someone else can't take over the account
+	if entity == nil || !entity.EmailVerified() {
+		return nil
+	}
+
+	buf := make([]byte, resetTokenSize)
+
+	_, err = rand.Read(buf)
+	if err != nil {
+		return requestid.Wrap(ctx, errors.WithStack(err), "error on read random bytes")
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+
+	err = svc.resetTokenRepo.Insert(ctx, PasswordResetToken{
+		TokenHash: hashToken(token),
+		UserUUID:  entity.UUID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(svc.passwordResetTTL),
+	})
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on insert password reset token")
+	}
+
+	link, err := tokenLink(svc.passwordResetURL, token)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on make password reset link")
+	}
+
+	err = svc.mailer.Send(ctx, mail.Message{
+		To:      []string{entity.Email},
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hi %s,\n\nOpen the link below to set a new password. It expires in %s and works once.\n\n%s\n\n"+
+			"If you didn't request it, you can ignore this email.\n", entity.Username, formatDuration(svc.passwordResetTTL), link),
+	})
+	if errors.Is(err, mail.ErrQueueFull{}) {
+		return err
+	}
+
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on send password reset mail")
+	}
+
+	return nil
+}
+
+func (svc *service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	now := time.Now()
+	tokenHash := hashToken(req.Token)
+
+	token, err := svc.resetTokenRepo.FindByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find password reset token by hash")
+	}
+
+	if token == nil || !token.UsedAt.IsZero() || now.After(token.ExpiresAt) {
+		return ErrInvalidPasswordResetToken{}
+	}
+
+	entity, err := svc.repo.FindByUUID(ctx, token.UserUUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find by uuid")
+	}
+
+	if entity == nil {
+		return ErrInvalidPasswordResetToken{}
+	}
+
+	err = validatePassword(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on hash password")
+	}
+
+	ok, err := svc.resetTokenRepo.MarkUsed(ctx, tokenHash, now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on mark password reset token used")
+	}
+
+	if !ok {
+		return ErrInvalidPasswordResetToken{}
+	}
+
+	entity.PasswordHash = passwordHash
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on update by uuid")
+	}
+
+	err = svc.sessionRepo.RevokeByUserUUID(ctx, entity.UUID, "", now)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on revoke sessions by user uuid")
+	}
+
+	// lockout of the account is lifted, since the owner proved access to the email
+	err = svc.attemptRepo.DeleteByKey(ctx, "account:"+strings.ToLower(entity.Username))
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete login attempts by key")
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 1000
+	maxLinkLength        = 2048
+	maxSocialLinks       = 10
+)
+
+func validLink(link string) bool {
+	if len(link) > maxLinkLength {
+		return false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validateProfile(profile Profile) (*Profile, error) {
+	res := Profile{
+		DisplayName:    strings.TrimSpace(profile.DisplayName),
+		Bio:            strings.TrimSpace(profile.Bio),
+		AvatarFileName: strings.TrimSpace(profile.AvatarFileName),
+		Website:        strings.TrimSpace(profile.Website),
+		SocialLinks:    make([]string, len(profile.SocialLinks)),
+	}
+
+	if utf8.RuneCountInString(res.DisplayName) > maxDisplayNameLength {
+		return nil, ErrInvalidDisplayName{}
+	}
+
+	if utf8.RuneCountInString(res.Bio) > maxBioLength {
+		return nil, ErrInvalidBio{}
+	}
+
+	if res.Website != "" && !validLink(res.Website) {
+		return nil, ErrInvalidLink{Link: res.Website}
+	}
+
+	if len(profile.SocialLinks) > maxSocialLinks {
+		return nil, ErrTooManySocialLinks{}
+	}
+
+	for i := range profile.SocialLinks {
+		res.SocialLinks[i] = strings.TrimSpace(profile.SocialLinks[i])
+
+		if !validLink(res.SocialLinks[i]) {
+			return nil, ErrInvalidLink{Link: res.SocialLinks[i]}
+		}
+	}
+
+	return &res, nil
+}
+
+func (svc *service) GetUserByUsername(ctx context.Context, username string) (*Entity, error) {
+	entity, err := svc.repo.FindByUsername(ctx, username)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by username")
+	}
+
+	if entity == nil {
+		return nil, ErrUserWithUsernameNotFound{Username: username}
+	}
+
+	return entity, nil
+}
+
+func (svc *service) UpdateProfile(ctx context.Context, req UpdateProfileRequest) (*Entity, error) {
+	profile, err := validateProfile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := svc.GetUserByUUID(ctx, req.UserUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the avatar is checked when it changes, like media of posts only own files can be used
+	if profile.AvatarFileName != "" && profile.AvatarFileName != entity.Profile.AvatarFileName {
+		f, err := svc.fileSvc.GetFileByName(ctx, profile.AvatarFileName)
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on get file by name")
+		}
+
+		if f.OwnerUUID != entity.UUID {
+			return nil, ErrFileNotAllowed{FileName: profile.AvatarFileName}
+		}
+
+		if !strings.HasPrefix(f.ContentType, "image/") {
+			return nil, ErrAvatarNotImage{FileName: profile.AvatarFileName}
+		}
+	}
+
+	entity.Profile = *profile
+
+	err = svc.repo.UpdateByUUID(ctx, entity.UUID, *entity)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on update user by uuid")
+	}
+
+	return entity, nil
+}
+
+func (svc *service) ListAvatarFileNames(ctx context.Context) ([]string, error) {
+	res, err := svc.repo.ListAvatarFileNames(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list avatar file names")
+	}
+
+	return res, nil
+}
@@ -2,9 +2,76 @@ package user
 
 import (
 	"context"
+	"time"
 )
 
 type Repository interface {
+	Insert(ctx context.Context, entity Entity) (err error)
 	FindByUsername(ctx context.Context, username string) (res *Entity, err error)
 	FindByUUID(ctx context.Context, userUUID string) (res *Entity, err error)
+	FindByEmail(ctx context.Context, email string) (res *Entity, err error)
+	UpdateByUUID(ctx context.Context, userUUID string, entity Entity) (err error)
+	DeleteByUUID(ctx context.Context, userUUID string) (err error)
+	Search(ctx context.Context, query string) (res []*Entity, err error)
+	ListAvatarFileNames(ctx context.Context) (res []string, err error)
+}
+
+type LoginAttemptRepository interface {
+	FindByKey(ctx context.Context, key string) (res *LoginAttempts, err error)
+	// Increment counts a failure atomically and returns the failures, failures before resetBefore are forgotten
+	Increment(ctx context.Context, key string, failedAt, resetBefore time.Time) (failures int, err error)
+	Lock(ctx context.Context, key string, lockedUntil time.Time) (err error)
+	DeleteByKey(ctx context.Context, key string) (err error)
+	DeleteExpired(ctx context.Context, failedBefore, now time.Time) (err error)
+}
+
+type TwoFactorRepository interface {
+	FindByUserUUID(ctx context.Context, userUUID string) (res *TwoFactor, err error)
+	Save(ctx context.Context, twoFactor TwoFactor) (err error)
+	DeleteByUserUUID(ctx context.Context, userUUID string) (err error)
+}
+
+type TwoFactorRequirementRepository interface {
+	ListRequiredRoles(ctx context.Context) (res []Role, err error)
+	SetRequired(ctx context.Context, role Role, required bool) (err error)
+}
+
+type SessionRepository interface {
+	Insert(ctx context.Context, session Session) (err error)
+	FindByID(ctx context.Context, id string) (res *Session, err error)
+	RevokeByID(ctx context.Context, id string, revokedAt time.Time) (err error)
+	// RevokeByUserUUID keeps the session with exceptID, empty exceptID revokes all
+	RevokeByUserUUID(ctx context.Context, userUUID, exceptID string, revokedAt time.Time) (err error)
+}
+
+type PasswordResetTokenRepository interface {
+	Insert(ctx context.Context, token PasswordResetToken) (err error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (res *PasswordResetToken, err error)
+	// MarkUsed returns false if the token is already used, so concurrent requests can't use it twice
+	MarkUsed(ctx context.Context, tokenHash string, usedAt time.Time) (ok bool, err error)
+}
+
+type InvitationRepository interface {
+	Insert(ctx context.Context, invitation Invitation) (err error)
+	FindByID(ctx context.Context, id string) (res *Invitation, err error)
+	MarkUsed(ctx context.Context, id string, usedAt time.Time) (ok bool, err error)
+}
+
+type IdentityRepository interface {
+	FindByProviderAndSubject(ctx context.Context, provider, subject string) (res *Identity, err error)
+	Insert(ctx context.Context, identity Identity) (err error)
+}
+
+type SigningKeyRepository interface {
+	Insert(ctx context.Context, key SigningKey) (err error)
+	List(ctx context.Context) (res []SigningKey, err error)
+	Promote(ctx context.Context, id string, promotedAt, retiresAt time.Time) (err error)
+}
+
+type APITokenRepository interface {
+	Insert(ctx context.Context, token APIToken) (err error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (res *APIToken, err error)
+	ListByUserUUID(ctx context.Context, userUUID string) (res []APIToken, err error)
+	UpdateLastUsedAt(ctx context.Context, tokenUUID string, lastUsedAt time.Time) (err error)
+	Revoke(ctx context.Context, tokenUUID, userUUID string, revokedAt time.Time) (ok bool, err error)
 }
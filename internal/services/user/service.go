@@ -1,19 +1,253 @@
 package user
 
-import "context"
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/jwk"
+	"github.com/nasermirzaei89/api/internal/mail"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/post"
+	"time"
+)
+
+type Config struct {
+	LoginAttemptRepository         LoginAttemptRepository
+	TwoFactorRepository            TwoFactorRepository
+	TwoFactorRequirementRepository TwoFactorRequirementRepository
+	SessionRepository              SessionRepository
+	PasswordResetTokenRepository   PasswordResetTokenRepository
+	InvitationRepository           InvitationRepository
+	IdentityRepository             IdentityRepository
+	SigningKeyRepository           SigningKeyRepository
+	APITokenRepository             APITokenRepository
+	FileService                    file.Service
+	PostService                    post.Service
+	Mailer                         mail.Mailer
+	Auditor                        Auditor
+	// SignKey and VerificationKey are an optional PEM encoded key pair used before signing keys
+	SignKey         []byte
+	VerificationKey []byte
+	// AccessTokenTTL is also the shortest overlap of signing keys
+	AccessTokenTTL       time.Duration
+	AccountLockout       LockoutPolicy
+	IPLockout            LockoutPolicy
+	TOTPIssuer           string
+	PasswordResetURL     string
+	PasswordResetTTL     time.Duration
+	InvitationURL        string
+	InvitationTTL        time.Duration
+	EmailVerificationURL string
+	EmailVerificationTTL time.Duration
+	// OIDCProvisionRoles by provider, users of providers without a role must exist
+	OIDCProvisionRoles map[string]Role
+}
 
 type Service interface {
 	LogIn(ctx context.Context, req LogInRequest) (res *LogInResponse, err error)
+	DeleteExpiredLoginAttempts(ctx context.Context) (err error)
 	GetUserByUUID(ctx context.Context, userID string) (res *Entity, err error)
+	GetUserByUsername(ctx context.Context, username string) (res *Entity, err error)
+	UpdateProfile(ctx context.Context, req UpdateProfileRequest) (res *Entity, err error)
+	ListAvatarFileNames(ctx context.Context) (res []string, err error)
 	GetUserByTokenString(ctx context.Context, tokenString string) (res *Entity, err error)
+	LogOut(ctx context.Context, accessToken string) (err error)
+	VerifyTwoFactor(ctx context.Context, req VerifyTwoFactorRequest) (res *LogInResponse, err error)
+	EnrollTwoFactor(ctx context.Context, req EnrollTwoFactorRequest) (res *EnrollTwoFactorResponse, err error)
+	EnableTwoFactor(ctx context.Context, req EnableTwoFactorRequest) (res *EnableTwoFactorResponse, err error)
+	DisableTwoFactor(ctx context.Context, req DisableTwoFactorRequest) (err error)
+	IsTwoFactorEnabled(ctx context.Context, userUUID string) (res bool, err error)
+	ListTwoFactorRequiredRoles(ctx context.Context) (res []Role, err error)
+	SetTwoFactorRequired(ctx context.Context, req SetTwoFactorRequiredRequest) (err error)
+	ChangePassword(ctx context.Context, req ChangePasswordRequest) (err error)
+	RequestPasswordReset(ctx context.Context, req RequestPasswordResetRequest) (err error)
+	ResetPassword(ctx context.Context, req ResetPasswordRequest) (err error)
+	InviteUser(ctx context.Context, req InviteUserRequest) (err error)
+	AcceptInvitation(ctx context.Context, req AcceptInvitationRequest) (res *Entity, err error)
+	UpdateEmail(ctx context.Context, req UpdateEmailRequest) (res *Entity, err error)
+	RequestEmailVerification(ctx context.Context, userUUID string) (err error)
+	VerifyEmail(ctx context.Context, req VerifyEmailRequest) (res *Entity, err error)
+	LogInWithOIDC(ctx context.Context, req LogInWithOIDCRequest) (res *LogInResponse, err error)
+	GetJWKS(ctx context.Context) (res *jwk.Set, err error)
+	GenerateSigningKey(ctx context.Context) (res *SigningKey, err error)
+	PromoteSigningKey(ctx context.Context, req PromoteSigningKeyRequest) (err error)
+	ListSigningKeys(ctx context.Context) (res []SigningKey, err error)
+	CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (res *CreateAPITokenResponse, err error)
+	ListAPITokens(ctx context.Context, userUUID string) (res []APIToken, err error)
+	RevokeAPIToken(ctx context.Context, req RevokeAPITokenRequest) (err error)
+	VerifyAPIToken(ctx context.Context, tokenString string) (res *APIToken, err error)
+	ListUsers(ctx context.Context, req ListUsersRequest) (res []*Entity, err error)
+	SetUserRole(ctx context.Context, req SetUserRoleRequest) (res *Entity, err error)
+	PromoteToAdmin(ctx context.Context, username string) (res *Entity, err error)
+	DisableUser(ctx context.Context, req DisableUserRequest) (res *Entity, err error)
+	EnableUser(ctx context.Context, req EnableUserRequest) (res *Entity, err error)
+	DeleteUser(ctx context.Context, req DeleteUserRequest) (err error)
 }
 
 type LogInRequest struct {
+	Username  string
+	Password  string
+	IP        string
+	UserAgent string
+}
+
+type LogInResponse struct {
+	AccessToken                 string
+	UserUUID                    string
+	ChallengeToken              string
+	TwoFactorEnrollmentRequired bool
+	RecoveryCodes               []string
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string
+	Code           string
+	IP             string
+	UserAgent      string
+}
+
+type EnrollTwoFactorRequest struct {
+	UserUUID       string
+	ChallengeToken string
+}
+
+type EnrollTwoFactorResponse struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+type EnableTwoFactorRequest struct {
+	UserUUID string
+	Code     string
+}
+
+type EnableTwoFactorResponse struct {
+	RecoveryCodes []string
+}
+
+type DisableTwoFactorRequest struct {
+	UserUUID string
+	Code     string
+}
+
+type SetTwoFactorRequiredRequest struct {
+	ActorUUID string
+	Role      Role
+	Required  bool
+	IP        string
+	UserAgent string
+}
+
+type Auditor interface {
+	AuditLogin(ctx context.Context, event LoginEvent)
+	AuditAdminAction(ctx context.Context, event AdminEvent)
+}
+
+type ChangePasswordRequest struct {
+	AccessToken     string
+	CurrentPassword string
+	NewPassword     string
+}
+
+type RequestPasswordResetRequest struct {
+	Email string
+}
+
+type ResetPasswordRequest struct {
+	Token       string
+	NewPassword string
+}
+
+type InviteUserRequest struct {
+	ActorUUID string
+	Email     string
+	Role      Role
+	IP        string
+	UserAgent string
+}
+
+type AcceptInvitationRequest struct {
+	Token    string
 	Username string
 	Password string
 }
 
-type LogInResponse struct {
-	AccessToken string
-	UserUUID    string
+type UpdateEmailRequest struct {
+	UserUUID string
+	Email    string
+}
+
+type UpdateProfileRequest struct {
+	UserUUID string
+	Profile  Profile
+}
+
+type VerifyEmailRequest struct {
+	Token string
+}
+
+// LogInWithOIDCRequest has claims verified by the provider
+type LogInWithOIDCRequest struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	IP                string
+	UserAgent         string
+}
+
+type PromoteSigningKeyRequest struct {
+	KeyID   string
+	Overlap time.Duration
+}
+
+type CreateAPITokenRequest struct {
+	UserUUID  string
+	Name      string
+	Scopes    []Scope
+	ExpiresAt time.Time
+}
+
+type CreateAPITokenResponse struct {
+	Token    string
+	APIToken APIToken
+}
+
+type RevokeAPITokenRequest struct {
+	UserUUID  string
+	TokenUUID string
+}
+
+type ListUsersRequest struct {
+	ActorUUID string
+	Query     string
+}
+
+type SetUserRoleRequest struct {
+	ActorUUID string
+	UserUUID  string
+	Role      Role
+	IP        string
+	UserAgent string
+}
+
+type DisableUserRequest struct {
+	ActorUUID string
+	UserUUID  string
+	IP        string
+	UserAgent string
+}
+
+type EnableUserRequest struct {
+	ActorUUID string
+	UserUUID  string
+	IP        string
+	UserAgent string
+}
+
+type DeleteUserRequest struct {
+	ActorUUID  string
+	UserUUID   string
+	ReassignTo string
+	IP         string
+	UserAgent  string
 }
@@ -0,0 +1,328 @@
+package user
+
+import (
+	"context"
+	"github.com/nasermirzaei89/api/internal/jwk"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingService starts a span for each call of the next service
+type tracingService struct {
+	next Service
+}
+
+func (svc *tracingService) LogIn(ctx context.Context, req LogInRequest) (*LogInResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.LogIn")
+	res, err := svc.next.LogIn(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) DeleteExpiredLoginAttempts(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "user.DeleteExpiredLoginAttempts")
+	err := svc.next.DeleteExpiredLoginAttempts(ctx)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) GetUserByUUID(ctx context.Context, userID string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.GetUserByUUID", trace.WithAttributes(label.String("user.uuid", userID)))
+	res, err := svc.next.GetUserByUUID(ctx, userID)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GetUserByUsername(ctx context.Context, username string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.GetUserByUsername", trace.WithAttributes(label.String("user.username", username)))
+	res, err := svc.next.GetUserByUsername(ctx, username)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) UpdateProfile(ctx context.Context, req UpdateProfileRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.UpdateProfile", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.UpdateProfile(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListAvatarFileNames(ctx context.Context) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "user.ListAvatarFileNames")
+	res, err := svc.next.ListAvatarFileNames(ctx)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GetUserByTokenString(ctx context.Context, tokenString string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.GetUserByTokenString")
+	res, err := svc.next.GetUserByTokenString(ctx, tokenString)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) LogOut(ctx context.Context, accessToken string) error {
+	ctx, span := tracing.Start(ctx, "user.LogOut")
+	err := svc.next.LogOut(ctx, accessToken)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) VerifyTwoFactor(ctx context.Context, req VerifyTwoFactorRequest) (*LogInResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.VerifyTwoFactor")
+	res, err := svc.next.VerifyTwoFactor(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) EnrollTwoFactor(ctx context.Context, req EnrollTwoFactorRequest) (*EnrollTwoFactorResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.EnrollTwoFactor")
+	res, err := svc.next.EnrollTwoFactor(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) EnableTwoFactor(ctx context.Context, req EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.EnableTwoFactor", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.EnableTwoFactor(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) DisableTwoFactor(ctx context.Context, req DisableTwoFactorRequest) error {
+	ctx, span := tracing.Start(ctx, "user.DisableTwoFactor", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	err := svc.next.DisableTwoFactor(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) IsTwoFactorEnabled(ctx context.Context, userUUID string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "user.IsTwoFactorEnabled", trace.WithAttributes(label.String("user.uuid", userUUID)))
+	res, err := svc.next.IsTwoFactorEnabled(ctx, userUUID)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListTwoFactorRequiredRoles(ctx context.Context) ([]Role, error) {
+	ctx, span := tracing.Start(ctx, "user.ListTwoFactorRequiredRoles")
+	res, err := svc.next.ListTwoFactorRequiredRoles(ctx)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) SetTwoFactorRequired(ctx context.Context, req SetTwoFactorRequiredRequest) error {
+	ctx, span := tracing.Start(ctx, "user.SetTwoFactorRequired", trace.WithAttributes(label.String("user.role", string(req.Role))))
+	err := svc.next.SetTwoFactorRequired(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) ChangePassword(ctx context.Context, req ChangePasswordRequest) error {
+	ctx, span := tracing.Start(ctx, "user.ChangePassword")
+	err := svc.next.ChangePassword(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) RequestPasswordReset(ctx context.Context, req RequestPasswordResetRequest) error {
+	ctx, span := tracing.Start(ctx, "user.RequestPasswordReset")
+	err := svc.next.RequestPasswordReset(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	ctx, span := tracing.Start(ctx, "user.ResetPassword")
+	err := svc.next.ResetPassword(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) InviteUser(ctx context.Context, req InviteUserRequest) error {
+	ctx, span := tracing.Start(ctx, "user.InviteUser", trace.WithAttributes(label.String("user.role", string(req.Role))))
+	err := svc.next.InviteUser(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) AcceptInvitation(ctx context.Context, req AcceptInvitationRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.AcceptInvitation")
+	res, err := svc.next.AcceptInvitation(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) UpdateEmail(ctx context.Context, req UpdateEmailRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.UpdateEmail", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.UpdateEmail(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) RequestEmailVerification(ctx context.Context, userUUID string) error {
+	ctx, span := tracing.Start(ctx, "user.RequestEmailVerification", trace.WithAttributes(label.String("user.uuid", userUUID)))
+	err := svc.next.RequestEmailVerification(ctx, userUUID)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) VerifyEmail(ctx context.Context, req VerifyEmailRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.VerifyEmail")
+	res, err := svc.next.VerifyEmail(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) LogInWithOIDC(ctx context.Context, req LogInWithOIDCRequest) (*LogInResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.LogInWithOIDC", trace.WithAttributes(label.String("oidc.provider", req.Provider)))
+	res, err := svc.next.LogInWithOIDC(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GetJWKS(ctx context.Context) (*jwk.Set, error) {
+	ctx, span := tracing.Start(ctx, "user.GetJWKS")
+	res, err := svc.next.GetJWKS(ctx)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) GenerateSigningKey(ctx context.Context) (*SigningKey, error) {
+	ctx, span := tracing.Start(ctx, "user.GenerateSigningKey")
+	res, err := svc.next.GenerateSigningKey(ctx)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) PromoteSigningKey(ctx context.Context, req PromoteSigningKeyRequest) error {
+	ctx, span := tracing.Start(ctx, "user.PromoteSigningKey", trace.WithAttributes(label.String("signing_key.id", req.KeyID)))
+	err := svc.next.PromoteSigningKey(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	ctx, span := tracing.Start(ctx, "user.ListSigningKeys")
+	res, err := svc.next.ListSigningKeys(ctx)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	ctx, span := tracing.Start(ctx, "user.CreateAPIToken", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.CreateAPIToken(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListAPITokens(ctx context.Context, userUUID string) ([]APIToken, error) {
+	ctx, span := tracing.Start(ctx, "user.ListAPITokens", trace.WithAttributes(label.String("user.uuid", userUUID)))
+	res, err := svc.next.ListAPITokens(ctx, userUUID)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) RevokeAPIToken(ctx context.Context, req RevokeAPITokenRequest) error {
+	ctx, span := tracing.Start(ctx, "user.RevokeAPIToken", trace.WithAttributes(label.String("user.uuid", req.UserUUID), label.String("api_token.uuid", req.TokenUUID)))
+	err := svc.next.RevokeAPIToken(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+func (svc *tracingService) VerifyAPIToken(ctx context.Context, tokenString string) (*APIToken, error) {
+	ctx, span := tracing.Start(ctx, "user.VerifyAPIToken")
+	res, err := svc.next.VerifyAPIToken(ctx, tokenString)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) ListUsers(ctx context.Context, req ListUsersRequest) ([]*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.ListUsers")
+	res, err := svc.next.ListUsers(ctx, req)
+	span.SetAttributes(label.Int("user.count", len(res)))
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) SetUserRole(ctx context.Context, req SetUserRoleRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.SetUserRole", trace.WithAttributes(label.String("user.uuid", req.UserUUID), label.String("user.role", string(req.Role))))
+	res, err := svc.next.SetUserRole(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) PromoteToAdmin(ctx context.Context, username string) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.PromoteToAdmin", trace.WithAttributes(label.String("user.username", username)))
+	res, err := svc.next.PromoteToAdmin(ctx, username)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) DisableUser(ctx context.Context, req DisableUserRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.DisableUser", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.DisableUser(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) EnableUser(ctx context.Context, req EnableUserRequest) (*Entity, error) {
+	ctx, span := tracing.Start(ctx, "user.EnableUser", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	res, err := svc.next.EnableUser(ctx, req)
+	tracing.End(span, err)
+
+	return res, err
+}
+
+func (svc *tracingService) DeleteUser(ctx context.Context, req DeleteUserRequest) error {
+	ctx, span := tracing.Start(ctx, "user.DeleteUser", trace.WithAttributes(label.String("user.uuid", req.UserUUID)))
+	err := svc.next.DeleteUser(ctx, req)
+	tracing.End(span, err)
+
+	return err
+}
+
+// NewTracingService wraps the service, so each call is traced
+func NewTracingService(next Service) Service {
+	svc := tracingService{
+		next: next,
+	}
+
+	return &svc
+}
@@ -0,0 +1,405 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/totp"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10
+	// totpSkew accepts codes of adjacent time steps, so clocks can drift
+	totpSkew = 1
+)
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		buf := make([]byte, recoveryCodeSize)
+
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, nil, errors.Wrap(errors.WithStack(err), "error on read random bytes")
+		}
+
+		code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		codes[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyCode marks the used step or recovery code on the entity, so it can't be used again once saved
+func verifyCode(twoFactor *TwoFactor, code string, now time.Time) (bool, error) {
+	step, ok, err := totp.Validate(twoFactor.Secret, code, now, totpSkew)
+	if err != nil {
+		return false, errors.Wrap(err, "error on validate totp code")
+	}
+
+	if ok {
+		if step <= twoFactor.LastUsedStep {
+			return false, nil
+		}
+
+		twoFactor.LastUsedStep = step
+
+		return true, nil
+	}
+
+	if !twoFactor.Enabled() {
+		return false, nil
+	}
+
+	hash := hashRecoveryCode(code)
+	for i := range twoFactor.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(twoFactor.RecoveryCodeHashes[i]), []byte(hash)) == 1 {
+			twoFactor.RecoveryCodeHashes = append(twoFactor.RecoveryCodeHashes[:i:i], twoFactor.RecoveryCodeHashes[i+1:]...)
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (svc *service) isTwoFactorRequired(ctx context.Context, role Role) (bool, error) {
+	roles, err := svc.requirementRepo.ListRequiredRoles(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "error on list required roles")
+	}
+
+	for i := range roles {
+		if roles[i] == role {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkCode throttles codes like passwords, since there are only a million
+func (svc *service) checkCode(ctx context.Context, twoFactor *TwoFactor, code string, now time.Time) error {
+	key := "two_factor:" + twoFactor.UserUUID
+
+	retryAfter, err := svc.lockedFor(ctx, key, now)
+	if err != nil {
+		return errors.Wrap(err, "error on check two factor lock")
+	}
+
+	if retryAfter > 0 {
+		return ErrLoginThrottled{RetryAfter: retryAfter}
+	}
+
+	ok, err := verifyCode(twoFactor, code, now)
+	if err != nil {
+		return errors.Wrap(err, "error on verify code")
+	}
+
+	if !ok {
+		err = svc.recordFailure(ctx, key, svc.accountLockout, now)
+		if err != nil {
+			return errors.Wrap(err, "error on record two factor failure")
+		}
+
+		return ErrInvalidTwoFactorCode{}
+	}
+
+	err = svc.attemptRepo.DeleteByKey(ctx, key)
+	if err != nil {
+		return errors.Wrap(err, "error on delete login attempts by key")
+	}
+
+	return nil
+}
+
+func (svc *service) VerifyTwoFactor(ctx context.Context, req VerifyTwoFactorRequest) (*LogInResponse, error) {
+	now := time.Now()
+
+	claims, err := svc.verifyToken(ctx, req.ChallengeToken, purposeTwoFactorChallenge)
+	if err != nil {
+		return nil, ErrInvalidChallengeToken{}
+	}
+
+	entity, err := svc.repo.FindByUUID(ctx, claims.Subject)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find by uuid")
+	}
+
+	if entity == nil {
+		return nil, ErrInvalidChallengeToken{}
+	}
+
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, entity.UUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	event := LoginEvent{
+		UserUUID:  entity.UUID,
+		Username:  entity.Username,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		TwoFactor: true,
+		CreatedAt: now,
+	}
+
+	// the user may be disabled after the challenge was issued
+	if entity.Disabled() {
+		event.Reason = "user is disabled"
+		svc.audit(ctx, event)
+
+		return nil, ErrUserDisabled{}
+	}
+
+	if twoFactor == nil {
+		return nil, ErrTwoFactorNotEnrolled{}
+	}
+
+	err = svc.checkCode(ctx, twoFactor, req.Code, now)
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case ErrLoginThrottled:
+			event.Reason = "throttled"
+		case ErrInvalidTwoFactorCode:
+			event.Reason = "invalid two-factor code"
+		default:
+			return nil, requestid.Wrap(ctx, err, "error on check code")
+		}
+
+		svc.audit(ctx, event)
+
+		return nil, errors.Cause(err)
+	}
+
+	rsp := LogInResponse{
+		UserUUID: entity.UUID,
+	}
+
+	if !twoFactor.Enabled() {
+		twoFactor.EnabledAt = now
+
+		rsp.RecoveryCodes, twoFactor.RecoveryCodeHashes, err = generateRecoveryCodes()
+		if err != nil {
+			return nil, requestid.Wrap(ctx, err, "error on generate recovery codes")
+		}
+	}
+
+	err = svc.twoFactorRepo.Save(ctx, *twoFactor)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on save two factor")
+	}
+
+	rsp.AccessToken, err = svc.issueAccessToken(ctx, entity, req.IP, req.UserAgent)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on issue access token")
+	}
+
+	event.Success = true
+	svc.audit(ctx, event)
+
+	return &rsp, nil
+}
+
+func (svc *service) EnrollTwoFactor(ctx context.Context, req EnrollTwoFactorRequest) (*EnrollTwoFactorResponse, error) {
+	userUUID := req.UserUUID
+
+	if userUUID == "" {
+		claims, err := svc.verifyToken(ctx, req.ChallengeToken, purposeTwoFactorChallenge)
+		if err != nil {
+			return nil, ErrInvalidChallengeToken{}
+		}
+
+		userUUID = claims.Subject
+	}
+
+	entity, err := svc.GetUserByUUID(ctx, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, entity.UUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	if twoFactor != nil && twoFactor.Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled{}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on generate totp secret")
+	}
+
+	err = svc.twoFactorRepo.Save(ctx, TwoFactor{
+		UserUUID:  entity.UUID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on save two factor")
+	}
+
+	rsp := EnrollTwoFactorResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.URI(svc.totpIssuer, entity.Username, secret),
+	}
+
+	return &rsp, nil
+}
+
+func (svc *service) EnableTwoFactor(ctx context.Context, req EnableTwoFactorRequest) (*EnableTwoFactorResponse, error) {
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, req.UserUUID)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	if twoFactor == nil {
+		return nil, ErrTwoFactorNotEnrolled{}
+	}
+
+	if twoFactor.Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled{}
+	}
+
+	now := time.Now()
+
+	err = svc.checkCode(ctx, twoFactor, req.Code, now)
+	if err != nil {
+		switch cause := errors.Cause(err).(type) {
+		case ErrLoginThrottled, ErrInvalidTwoFactorCode:
+			return nil, cause
+		default:
+			return nil, requestid.Wrap(ctx, err, "error on check code")
+		}
+	}
+
+	var rsp EnableTwoFactorResponse
+
+	twoFactor.EnabledAt = now
+
+	rsp.RecoveryCodes, twoFactor.RecoveryCodeHashes, err = generateRecoveryCodes()
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on generate recovery codes")
+	}
+
+	err = svc.twoFactorRepo.Save(ctx, *twoFactor)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on save two factor")
+	}
+
+	return &rsp, nil
+}
+
+func (svc *service) DisableTwoFactor(ctx context.Context, req DisableTwoFactorRequest) error {
+	entity, err := svc.GetUserByUUID(ctx, req.UserUUID)
+	if err != nil {
+		return err
+	}
+
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, entity.UUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	if twoFactor == nil || !twoFactor.Enabled() {
+		return ErrTwoFactorNotEnrolled{}
+	}
+
+	required, err := svc.isTwoFactorRequired(ctx, entity.Role)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on check two factor requirement")
+	}
+
+	if required {
+		return ErrTwoFactorRequired{Role: entity.Role}
+	}
+
+	err = svc.checkCode(ctx, twoFactor, req.Code, time.Now())
+	if err != nil {
+		switch cause := errors.Cause(err).(type) {
+		case ErrLoginThrottled, ErrInvalidTwoFactorCode:
+			return cause
+		default:
+			return requestid.Wrap(ctx, err, "error on check code")
+		}
+	}
+
+	err = svc.twoFactorRepo.DeleteByUserUUID(ctx, entity.UUID)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on delete two factor by user uuid")
+	}
+
+	return nil
+}
+
+func (svc *service) IsTwoFactorEnabled(ctx context.Context, userUUID string) (bool, error) {
+	twoFactor, err := svc.twoFactorRepo.FindByUserUUID(ctx, userUUID)
+	if err != nil {
+		return false, requestid.Wrap(ctx, err, "error on find two factor by user uuid")
+	}
+
+	return twoFactor != nil && twoFactor.Enabled(), nil
+}
+
+func (svc *service) ListTwoFactorRequiredRoles(ctx context.Context) ([]Role, error) {
+	res, err := svc.requirementRepo.ListRequiredRoles(ctx)
+	if err != nil {
+		return nil, requestid.Wrap(ctx, err, "error on list required roles")
+	}
+
+	return res, nil
+}
+
+func (svc *service) SetTwoFactorRequired(ctx context.Context, req SetTwoFactorRequiredRequest) error {
+	actor, err := svc.admin(ctx, req.ActorUUID)
+	if err != nil {
+		return err
+	}
+
+	if !req.Role.Valid() {
+		return ErrInvalidRole{Role: req.Role}
+	}
+
+	previous, err := svc.isTwoFactorRequired(ctx, req.Role)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on check two factor requirement")
+	}
+
+	err = svc.requirementRepo.SetRequired(ctx, req.Role, req.Required)
+	if err != nil {
+		return requestid.Wrap(ctx, err, "error on set two factor required")
+	}
+
+	svc.auditAdminAction(ctx, AdminEvent{
+		ActorUUID:  actor.UUID,
+		Action:     "two_factor.set_required",
+		TargetType: "role",
+		TargetID:   string(req.Role),
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		Changes:    map[string]Change{"twoFactorRequired": {From: previous, To: req.Required}},
+		CreatedAt:  time.Now(),
+	})
+
+	return nil
+}
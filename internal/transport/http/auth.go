@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"github.com/gorilla/mux"
+	"github.com/nasermirzaei89/api/internal/logger"
 	"github.com/nasermirzaei89/api/internal/services/user"
 	"net/http"
 	"strings"
@@ -10,18 +11,26 @@ import (
 
 type contextKey string
 
-const contextKeyUserUUID contextKey = "userUUID"
+const (
+	contextKeyUserUUID      contextKey = "userUUID"
+	contextKeyUserRole      contextKey = "userRole"
+	contextKeyAccessToken   contextKey = "accessToken"
+	contextKeyAPIToken      contextKey = "apiToken"
+	contextKeySessionWriter contextKey = "sessionWriter"
+)
 
 type authMW struct {
 	next    http.Handler
 	userSvc user.Service
+	cookies *sessionCookies
 }
 
-func authenticate(userSvc user.Service) mux.MiddlewareFunc {
+func authenticate(userSvc user.Service, cookies *sessionCookies) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return &authMW{
 			next:    next,
 			userSvc: userSvc,
+			cookies: cookies,
 		}
 	}
 }
@@ -29,6 +38,11 @@ func authenticate(userSvc user.Service) mux.MiddlewareFunc {
 func (mw *authMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
+		if cookie, err := r.Cookie(sessionCookieName); err == nil && mw.cookies != nil && cookie.Value != "" {
+			mw.serveSessionCookie(w, r, cookie.Value)
+			return
+		}
+
 		mw.next.ServeHTTP(w, r)
 		return
 	}
@@ -40,13 +54,65 @@ func (mw *authMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	tokenString := authHeader[7:]
 
+	if strings.HasPrefix(tokenString, user.APITokenPrefix) {
+		mw.serveAPIToken(w, r, tokenString)
+		return
+	}
+
 	usr, err := mw.userSvc.GetUserByTokenString(r.Context(), tokenString)
 	if err != nil {
 		respond(w, r, unauthorized("invalid authorization header"))
 		return
 	}
 
-	r = r.WithContext(context.WithValue(r.Context(), contextKeyUserUUID, usr.UUID))
+	mw.serveUser(w, r, usr, tokenString)
+}
+
+func (mw *authMW) serveSessionCookie(w http.ResponseWriter, r *http.Request, tokenString string) {
+	// other sites can send the cookie but can't read the csrf token, safe requests without it are served anonymously
+	if !mw.cookies.validCSRFToken(r) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			mw.next.ServeHTTP(w, r)
+			return
+		}
+
+		respond(w, r, forbidden("invalid csrf token"))
+		return
+	}
+
+	usr, err := mw.userSvc.GetUserByTokenString(r.Context(), tokenString)
+	if err != nil {
+		mw.cookies.clear(w, r)
+		respond(w, r, unauthorized("invalid session cookie"))
+		return
+	}
+
+	mw.serveUser(w, r, usr, tokenString)
+}
+
+func (mw *authMW) serveUser(w http.ResponseWriter, r *http.Request, usr *user.Entity, tokenString string) {
+	ctx := context.WithValue(r.Context(), contextKeyUserUUID, usr.UUID)
+	ctx = context.WithValue(ctx, contextKeyUserRole, usr.Role)
+	ctx = context.WithValue(ctx, contextKeyAccessToken, tokenString)
+	r = r.WithContext(ctx)
+
+	addLogFields(r.Context(), logger.Fields{"userUUID": usr.UUID})
+
+	mw.next.ServeHTTP(w, r)
+}
+
+func (mw *authMW) serveAPIToken(w http.ResponseWriter, r *http.Request, tokenString string) {
+	apiToken, err := mw.userSvc.VerifyAPIToken(r.Context(), tokenString)
+	if err != nil {
+		respond(w, r, unauthorized("invalid authorization header"))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), contextKeyUserUUID, apiToken.UserUUID)
+	ctx = context.WithValue(ctx, contextKeyAPIToken, apiToken)
+	r = r.WithContext(ctx)
+
+	addLogFields(r.Context(), logger.Fields{"userUUID": apiToken.UserUUID, "apiTokenUUID": apiToken.UUID})
 
 	mw.next.ServeHTTP(w, r)
 }
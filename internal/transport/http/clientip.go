@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const contextKeyClient contextKey = "client"
+
+type client struct {
+	IP        string
+	UserAgent string
+}
+
+func clientFromContext(ctx context.Context) client {
+	c, _ := ctx.Value(contextKeyClient).(client)
+
+	return c
+}
+
+// clientIP trusts X-Forwarded-For only behind a trusted proxy
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func isHTTPS(r *http.Request, trustProxy bool) bool {
+	if trustProxy {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			return strings.EqualFold(proto, "https")
+		}
+	}
+
+	return r.TLS != nil
+}
+
+type clientMW struct {
+	next       http.Handler
+	trustProxy bool
+}
+
+func identifyClient(trustProxy bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &clientMW{
+			next:       next,
+			trustProxy: trustProxy,
+		}
+	}
+}
+
+func (mw *clientMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := client{
+		IP:        clientIP(r, mw.trustProxy),
+		UserAgent: r.UserAgent(),
+	}
+
+	mw.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyClient, c)))
+}
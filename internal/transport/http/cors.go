@@ -6,9 +6,8 @@ import (
 	"net/http"
 )
 
-func cors() mux.MiddlewareFunc {
-	return handlers.CORS(
-		handlers.AllowedOrigins([]string{"*"}),
+func cors(allowedOrigins []string) mux.MiddlewareFunc {
+	options := []handlers.CORSOption{
 		handlers.AllowedMethods([]string{
 			http.MethodOptions,
 			http.MethodHead,
@@ -24,7 +23,21 @@ func cors() mux.MiddlewareFunc {
 			"Accept",
 			"Accept-Language",
 			"Origin",
+			headerRequestID,
+			headerCSRFToken,
 		}),
-		handlers.AllowCredentials(),
-	)
+		handlers.ExposedHeaders([]string{
+			headerRequestID,
+			"Retry-After",
+			"RateLimit-Limit",
+			"RateLimit-Remaining",
+			"RateLimit-Reset",
+		}),
+	}
+
+	if len(allowedOrigins) == 0 {
+		return handlers.CORS(append(options, handlers.AllowedOrigins([]string{"*"}))...)
+	}
+
+	return handlers.CORS(append(options, handlers.AllowedOrigins(allowedOrigins), handlers.AllowCredentials())...)
 }
@@ -1,11 +1,133 @@
 package http
 
 import (
+	"context"
+	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/user"
 	"github.com/pkg/errors"
+	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 )
 
+var errUploadTooLarge = errors.New("upload too large")
+
+type maxSizeReader struct {
+	r io.Reader
+	n int64
+}
+
+func (mr *maxSizeReader) Read(p []byte) (int, error) {
+	if mr.n < 0 {
+		return 0, errUploadTooLarge
+	}
+
+	if int64(len(p)) > mr.n+1 {
+		p = p[:mr.n+1]
+	}
+
+	n, err := mr.r.Read(p)
+	mr.n -= int64(n)
+	if mr.n < 0 {
+		return n, errUploadTooLarge
+	}
+
+	return n, err
+}
+
+func (h *handler) limitUpload(r io.Reader) io.Reader {
+	if h.maxUploadSize <= 0 {
+		return r
+	}
+
+	return &maxSizeReader{r: r, n: h.maxUploadSize}
+}
+
+func (h *handler) uploadTooLarge() Problem {
+	return requestEntityTooLarge(fmt.Sprintf("file size exceeds %d bytes", h.maxUploadSize))
+}
+
+func storageQuotaExceeded(err file.ErrStorageQuotaExceeded) Problem {
+	return insufficientStorage("storage quota exceeded",
+		setExtension("usedBytes", err.Usage.Bytes),
+		setExtension("usedFiles", err.Usage.Files),
+		setExtension("maxBytes", err.Quota.MaxBytes),
+		setExtension("maxFiles", err.Quota.MaxFiles),
+	)
+}
+
+func contentTypeProblem(err error) (Problem, bool) {
+	var errUnsupported file.ErrUnsupportedContentType
+	if errors.As(err, &errUnsupported) {
+		return unsupportedMediaType(errUnsupported.Error()), true
+	}
+
+	var errMismatch file.ErrContentTypeMismatch
+	if errors.As(err, &errMismatch) {
+		return unsupportedMediaType(errMismatch.Error()), true
+	}
+
+	var errInvalidImage file.ErrInvalidImage
+	if errors.As(err, &errInvalidImage) {
+		return unsupportedMediaType(errInvalidImage.Error()), true
+	}
+
+	return Problem{}, false
+}
+
+// other types could be active content like html or svg, so they are served as attachments
+var inlineTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/bmp",
+	"audio/",
+	"video/",
+}
+
+func isInline(contentType string) bool {
+	for i := range inlineTypes {
+		if strings.HasPrefix(contentType, inlineTypes[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *handler) uploadFile(ctx context.Context, userUUID, contentType string, r io.Reader) (*file.Entity, error) {
+	usr, err := h.userSvc.GetUserByUUID(ctx, userUUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on get user by uuid")
+	}
+
+	c := clientFromContext(ctx)
+	start := time.Now()
+
+	res, err := h.fileSvc.UploadFile(ctx, file.UploadFileRequest{
+		UserUUID:    usr.UUID,
+		UserRole:    string(usr.Role),
+		ContentType: contentType,
+		Reader:      h.limitUpload(r),
+		IP:          c.IP,
+		UserAgent:   c.UserAgent,
+	})
+	if err != nil {
+		h.metrics.observeUpload(0, time.Since(start), err)
+		return nil, err
+	}
+
+	h.metrics.observeUpload(res.Size, time.Since(start), nil)
+
+	return res, nil
+}
+
 func (h *handler) handleUploadFile() http.HandlerFunc {
 	type Response struct {
 		withStatusCreated
@@ -19,13 +141,41 @@ func (h *handler) handleUploadFile() http.HandlerFunc {
 			return
 		}
 
-		res, err := h.fileSvc.UploadFile(r.Context(), r.Body)
+		err := checkScope(r.Context(), user.ScopeFilesWrite)
 		if err != nil {
+			respond(w, r, forbidden(err.Error()))
+			return
+		}
+
+		res, err := h.uploadFile(r.Context(), userID.(string), r.Header.Get("Content-Type"), r.Body)
+		if err != nil {
+			if errors.Is(err, errUploadTooLarge) {
+				respond(w, r, h.uploadTooLarge())
+				return
+			}
+
+			if problem, ok := contentTypeProblem(err); ok {
+				respond(w, r, problem)
+				return
+			}
+
+			var errQuota file.ErrStorageQuotaExceeded
+			if errors.As(err, &errQuota) {
+				respond(w, r, storageQuotaExceeded(errQuota))
+				return
+			}
+
+			var errImageTooLarge file.ErrImageTooLarge
+			if errors.As(err, &errImageTooLarge) {
+				respond(w, r, requestEntityTooLarge(errImageTooLarge.Error(), setExtension("maxPixels", errImageTooLarge.MaxPixels)))
+				return
+			}
+
 			respond(w, r, internalServerError(errors.Wrap(err, "error on upload file")))
 			return
 		}
 
-		rsp := Response{FileName: res.FileName}
+		rsp := Response{FileName: res.Name}
 
 		respond(w, r, rsp)
 	}
@@ -46,6 +196,18 @@ func (h *handler) handleDownloadFile() http.HandlerFunc {
 			return
 		}
 
+		contentType := mime.TypeByExtension(path.Ext(fileName))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if !isInline(contentType) {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+		}
+
 		http.ServeContent(w, r, fileName, *lm, res)
 	}
 }
@@ -1,28 +1,91 @@
 package http
 
 import (
+	"encoding/base64"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
 	gqlhandler "github.com/graphql-go/handler"
 	"github.com/graphql-go/relay"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/services/audit"
+	"github.com/nasermirzaei89/api/internal/services/file"
 	"github.com/nasermirzaei89/api/internal/services/post"
 	"github.com/nasermirzaei89/api/internal/services/user"
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
 	"net/http"
+	"strings"
 	"time"
 )
 
 func (h *handler) handleGraphQL(pretty, graphiQL, playground bool) http.Handler {
 	schema := h.newSchema()
 
-	return gqlhandler.New(&gqlhandler.Config{
-		Schema:     &schema,
-		Pretty:     pretty,
-		GraphiQL:   graphiQL,
-		Playground: playground,
+	mh := h.handleGraphQLMultipart(&schema, pretty)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.sessionCookies != nil {
+			sw := sessionWriter{cookies: h.sessionCookies, w: w, r: r}
+			r = r.WithContext(context.WithValue(r.Context(), contextKeySessionWriter, &sw))
+		}
+
+		if isMultipartRequest(r) {
+			mh.ServeHTTP(w, r)
+			return
+		}
+
+		if !h.rateLimiter.allowGraphQLRequest(w, r) {
+			return
+		}
+
+		start := time.Now()
+
+		ctx, span := startGraphQLSpan(r.Context())
+		executed := false
+
+		// handler is created per request, so errors can be formatted with the request id
+		gqlhandler.New(&gqlhandler.Config{
+			Schema:        &schema,
+			Pretty:        pretty,
+			GraphiQL:      graphiQL,
+			Playground:    playground,
+			FormatErrorFn: formatGraphQLError(r.Context()),
+			ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+				executed = true
+
+				name, typ := graphQLOperation(params.RequestString, params.OperationName)
+				h.metrics.observeGraphQL(graphQLRootField(&schema, params.RequestString, params.OperationName), typ, len(result.Errors), time.Since(start))
+				endGraphQLSpan(span, name, typ, result)
+			},
+		}).ServeHTTP(w, r.WithContext(ctx))
+
+		if !executed {
+			span.End()
+		}
 	})
 }
 
+func formatGraphQLError(ctx context.Context) func(err error) gqlerrors.FormattedError {
+	return func(err error) gqlerrors.FormattedError {
+		res := gqlerrors.FormatError(err)
+
+		id := requestid.Of(err)
+		if id == "" {
+			id = requestid.FromContext(ctx)
+		}
+
+		if id != "" {
+			if res.Extensions == nil {
+				res.Extensions = make(map[string]interface{})
+			}
+
+			res.Extensions["request_id"] = id
+		}
+
+		return res
+	}
+}
+
 func (h *handler) newSchema() graphql.Schema {
 	query := graphql.NewObject(graphql.ObjectConfig{
 		Name:   "Query",
@@ -36,8 +99,13 @@ func (h *handler) newSchema() graphql.Schema {
 
 	query.AddFieldConfig("health", &graphql.Field{
 		Type: graphql.NewNonNull(graphql.Boolean),
-		Resolve: func(_ graphql.ResolveParams) (interface{}, error) {
-			return true, nil
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			res, err := h.healthSvc.CheckReadiness(p.Context)
+			if err != nil {
+				return nil, errors.Wrap(err, "error on check readiness")
+			}
+
+			return res.Ready, nil
 		},
 	})
 
@@ -51,6 +119,16 @@ func (h *handler) newSchema() graphql.Schema {
 			case "User":
 				return h.userSvc.GetUserByUUID(ctx, resolvedID.ID)
 			case "Post":
+				// posts are fetched like getPostByUUID, drafts aren't public
+				if ctx.Value(contextKeyUserUUID) == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				err := checkScope(ctx, user.ScopePostsRead)
+				if err != nil {
+					return nil, err
+				}
+
 				return h.postSvc.GetPostByUUID(ctx, resolvedID.ID)
 			default:
 				return nil, errors.New("unknown node type")
@@ -86,12 +164,242 @@ func (h *handler) newSchema() graphql.Schema {
 					return p.Source.(*user.Entity).Username, nil
 				},
 			},
+			"displayName": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*user.Entity).Profile.DisplayName), nil
+				},
+			},
+			"bio": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*user.Entity).Profile.Bio), nil
+				},
+			},
+			"website": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*user.Entity).Profile.Website), nil
+				},
+			},
+			"socialLinks": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if links := p.Source.(*user.Entity).Profile.SocialLinks; links != nil {
+						return links, nil
+					}
+
+					return []string{}, nil
+				},
+			},
+			"role": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null for anonymous callers",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if p.Context.Value(contextKeyUserUUID) == nil {
+						return nil, nil
+					}
+
+					return string(p.Source.(*user.Entity).Role), nil
+				},
+			},
+			"email": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null for users other than the authenticated user, unless the authenticated user is an admin",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					usr := p.Source.(*user.Entity)
+
+					if !privateFieldsVisible(p.Context, usr) {
+						return nil, nil
+					}
+
+					return nullString(usr.Email), nil
+				},
+			},
+			"emailVerified": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Null for users other than the authenticated user, unless the authenticated user is an admin",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					usr := p.Source.(*user.Entity)
+
+					if !privateFieldsVisible(p.Context, usr) {
+						return nil, nil
+					}
+
+					return usr.EmailVerified(), nil
+				},
+			},
+			"twoFactorEnabled": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Null for users other than the authenticated user, unless the authenticated user is an admin",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					usr := p.Source.(*user.Entity)
+
+					if !privateFieldsVisible(p.Context, usr) {
+						return nil, nil
+					}
+
+					return h.userSvc.IsTwoFactorEnabled(p.Context, usr.UUID)
+				},
+			},
+			"disabled": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Null for users other than the authenticated user, unless the authenticated user is an admin",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					usr := p.Source.(*user.Entity)
+
+					if !privateFieldsVisible(p.Context, usr) {
+						return nil, nil
+					}
+
+					return usr.Disabled(), nil
+				},
+			},
 		},
 		Interfaces: []*graphql.Interface{
 			nodeDefinitions.NodeInterface,
 		},
 	})
 
+	typeFocalPoint := graphql.NewObject(graphql.ObjectConfig{
+		Name: "FocalPoint",
+		Fields: graphql.Fields{
+			"x": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*post.FocalPoint).X, nil
+				},
+			},
+			"y": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*post.FocalPoint).Y, nil
+				},
+			},
+		},
+	})
+
+	typeFile := graphql.NewObject(graphql.ObjectConfig{
+		Name: "File",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return fileOf(p.Source).Name, nil
+				},
+			},
+			"contentType": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return fileOf(p.Source).ContentType, nil
+				},
+			},
+			"size": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return fileOf(p.Source).Size, nil
+				},
+			},
+			"width": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if width := fileOf(p.Source).Width; width != nil {
+						return *width, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"height": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if height := fileOf(p.Source).Height; height != nil {
+						return *height, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"dominantColor": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Hex color like `#336699` of images",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if v := fileOf(p.Source).DominantColor; v != "" {
+						return v, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"blurHash": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Placeholder of images, see https://blurha.sh",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if v := fileOf(p.Source).BlurHash; v != "" {
+						return v, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"altText": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Alternative text of the file when it is used by a post",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if m := mediaOf(p.Source); m != nil {
+						return m.AltText, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"caption": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Caption of the file when it is used by a post",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if m := mediaOf(p.Source); m != nil {
+						return m.Caption, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"focalPoint": &graphql.Field{
+				Type:        typeFocalPoint,
+				Description: "Focal point of the file when it is used by a post",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if m := mediaOf(p.Source); m != nil && m.FocalPoint != nil {
+						return m.FocalPoint, nil
+					}
+
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	typeUser.AddFieldConfig("avatar", &graphql.Field{
+		Type:        typeFile,
+		Description: "Null without avatar, or when its file is deleted",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			fileName := p.Source.(*user.Entity).Profile.AvatarFileName
+			if fileName == "" {
+				return nil, nil
+			}
+
+			f, err := h.fileSvc.GetFileByName(p.Context, fileName)
+			if err != nil {
+				if _, ok := errors.Cause(err).(file.ErrFileWithNameNotFound); ok {
+					return nil, nil
+				}
+
+				return nil, err
+			}
+
+			return f, nil
+		},
+	})
+
 	typePost = graphql.NewObject(graphql.ObjectConfig{
 		Name: "Post",
 		Fields: graphql.Fields{
@@ -102,6 +410,27 @@ func (h *handler) newSchema() graphql.Schema {
 				}
 				return "", errors.New("object is not a post")
 			}),
+			"author": &graphql.Field{
+				Type:        typeUser,
+				Description: "Null for posts of deleted users which weren't reassigned, and posts created before authors were recorded",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					authorUUID := p.Source.(*post.Entity).AuthorUUID
+					if authorUUID == "" {
+						return nil, nil
+					}
+
+					usr, err := h.userSvc.GetUserByUUID(p.Context, authorUUID)
+					if err != nil {
+						if _, ok := errors.Cause(err).(user.ErrUserWithUUIDNotFound); ok {
+							return nil, nil
+						}
+
+						return nil, err
+					}
+
+					return usr, nil
+				},
+			},
 			"title": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.String),
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
@@ -137,6 +466,35 @@ func (h *handler) newSchema() graphql.Schema {
 					return nil, nil
 				},
 			},
+			"coverImage": &graphql.Field{
+				Type: typeFile,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					coverImage := p.Source.(*post.Entity).CoverImage
+					if coverImage == nil {
+						return nil, nil
+					}
+
+					return h.postFile(p.Context, *coverImage)
+				},
+			},
+			"attachments": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(typeFile))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					attachments := p.Source.(*post.Entity).Attachments
+
+					res := make([]interface{}, len(attachments))
+					for i := range attachments {
+						f, err := h.postFile(p.Context, attachments[i])
+						if err != nil {
+							return nil, err
+						}
+
+						res[i] = f
+					}
+
+					return res, nil
+				},
+			},
 		},
 		Interfaces: []*graphql.Interface{
 			nodeDefinitions.NodeInterface,
@@ -157,60 +515,1264 @@ func (h *handler) newSchema() graphql.Schema {
 			"password": &graphql.InputObjectFieldConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"cookie": &graphql.InputObjectFieldConfig{
+				Type:         graphql.Boolean,
+				DefaultValue: false,
+				Description:  "Sets the access token as an HttpOnly session cookie instead of returning it",
+			},
 		},
 	})
 
 	typeLogInResponse := graphql.NewObject(graphql.ObjectConfig{
 		Name: "LogInResponse",
 		Fields: graphql.Fields{
-			"accessToken": &graphql.Field{
-				Type: graphql.NewNonNull(graphql.String),
+			"accessToken": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null when it is set as a cookie, or when two-factor authentication is required, exchange the challenge token with `verifyTwoFactor`",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*user.LogInResponse).AccessToken), nil
+				},
+			},
+			"challengeToken": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Short-lived token for `verifyTwoFactor`, and `enrollTwoFactor` when enrollment is required",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*user.LogInResponse).ChallengeToken), nil
+				},
+			},
+			"twoFactorRequired": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.LogInResponse).ChallengeToken != "", nil
+				},
+			},
+			"twoFactorEnrollmentRequired": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Role of the user requires two-factor authentication and it is not enabled yet",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.LogInResponse).TwoFactorEnrollmentRequired, nil
+				},
+			},
+			"recoveryCodes": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Returned once, when `verifyTwoFactor` enables two-factor authentication",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if codes := p.Source.(*user.LogInResponse).RecoveryCodes; len(codes) > 0 {
+						return codes, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(typeUser),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.userSvc.GetUserByUUID(p.Context, p.Source.(*user.LogInResponse).UserUUID)
+				},
+			},
+		},
+	})
+
+	query.AddFieldConfig("me",
+		&graphql.Field{
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				return h.userSvc.GetUserByUUID(p.Context, userID.(string))
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("logIn",
+		&graphql.Field{
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeLogInRequest),
+				},
+			},
+			Type: graphql.NewNonNull(typeLogInResponse),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := p.Args["request"].(map[string]interface{})
+				c := clientFromContext(p.Context)
+
+				var sw *sessionWriter
+				var err error
+
+				if cookie, _ := req["cookie"].(bool); cookie {
+					sw, err = sessionWriterFromContext(p.Context)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				res, err := h.userSvc.LogIn(p.Context, user.LogInRequest{
+					Username:  req["username"].(string),
+					Password:  req["password"].(string),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return sw.setAccessToken(res)
+			},
+		},
+	)
+
+	typeVerifyTwoFactorRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "VerifyTwoFactorRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"challengeToken": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"code": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "TOTP code or an unused recovery code",
+			},
+			"cookie": &graphql.InputObjectFieldConfig{
+				Type:         graphql.Boolean,
+				DefaultValue: false,
+				Description:  "Sets the access token as an HttpOnly session cookie instead of returning it",
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("verifyTwoFactor",
+		&graphql.Field{
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeVerifyTwoFactorRequest),
+				},
+			},
+			Type: graphql.NewNonNull(typeLogInResponse),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := p.Args["request"].(map[string]interface{})
+				c := clientFromContext(p.Context)
+
+				var sw *sessionWriter
+				var err error
+
+				if cookie, _ := req["cookie"].(bool); cookie {
+					sw, err = sessionWriterFromContext(p.Context)
+					if err != nil {
+						return nil, err
+					}
+				}
+
+				res, err := h.userSvc.VerifyTwoFactor(p.Context, user.VerifyTwoFactorRequest{
+					ChallengeToken: req["challengeToken"].(string),
+					Code:           req["code"].(string),
+					IP:             c.IP,
+					UserAgent:      c.UserAgent,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return sw.setAccessToken(res)
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("logOut",
+		&graphql.Field{
+			Description: "Revokes the session of the access token and clears session cookies",
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				accessToken, _ := p.Context.Value(contextKeyAccessToken).(string)
+				if accessToken == "" {
+					return nil, errors.New("unauthorized request")
+				}
+
+				err := h.userSvc.LogOut(p.Context, accessToken)
+				if err != nil {
+					return nil, err
+				}
+
+				if sw, ok := p.Context.Value(contextKeySessionWriter).(*sessionWriter); ok {
+					sw.cookies.clear(sw.w, sw.r)
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	typeTwoFactorEnrollment := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TwoFactorEnrollment",
+		Fields: graphql.Fields{
+			"secret": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Base32 secret for entering manually in authenticator apps",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.EnrollTwoFactorResponse).Secret, nil
+				},
+			},
+			"provisioningURI": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "`otpauth://` uri to be shown as a QR code",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.EnrollTwoFactorResponse).ProvisioningURI, nil
+				},
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("enrollTwoFactor",
+		&graphql.Field{
+			Description: "Starts a pending enrollment of the authenticated user, or of the user of the challenge token",
+			Args: graphql.FieldConfigArgument{
+				"challengeToken": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Type: graphql.NewNonNull(typeTwoFactorEnrollment),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID, _ := p.Context.Value(contextKeyUserUUID).(string)
+				challengeToken, _ := p.Args["challengeToken"].(string)
+
+				if userID == "" && challengeToken == "" {
+					return nil, errors.New("unauthorized request")
+				}
+
+				return h.userSvc.EnrollTwoFactor(p.Context, user.EnrollTwoFactorRequest{
+					UserUUID:       userID,
+					ChallengeToken: challengeToken,
+				})
+			},
+		},
+	)
+
+	typeEnableTwoFactorResponse := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EnableTwoFactorResponse",
+		Fields: graphql.Fields{
+			"recoveryCodes": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Each code can be used once instead of a TOTP code, they are not shown again",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*user.EnableTwoFactorResponse).RecoveryCodes, nil
+				},
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("enableTwoFactor",
+		&graphql.Field{
+			Description: "Confirms the pending enrollment with a TOTP code",
+			Args: graphql.FieldConfigArgument{
+				"code": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeEnableTwoFactorResponse),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				return h.userSvc.EnableTwoFactor(p.Context, user.EnableTwoFactorRequest{
+					UserUUID: userID.(string),
+					Code:     p.Args["code"].(string),
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("disableTwoFactor",
+		&graphql.Field{
+			Args: graphql.FieldConfigArgument{
+				"code": &graphql.ArgumentConfig{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "TOTP code or an unused recovery code",
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				err := h.userSvc.DisableTwoFactor(p.Context, user.DisableTwoFactorRequest{
+					UserUUID: userID.(string),
+					Code:     p.Args["code"].(string),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	query.AddFieldConfig("twoFactorRequiredRoles",
+		&graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				return h.userSvc.ListTwoFactorRequiredRoles(p.Context)
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("setTwoFactorRequired",
+		&graphql.Field{
+			Description: "Requires two-factor authentication for users of the role, only admins can set it",
+			Args: graphql.FieldConfigArgument{
+				"role": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"required": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Boolean),
+				},
+			},
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				c := clientFromContext(p.Context)
+
+				err := h.userSvc.SetTwoFactorRequired(p.Context, user.SetTwoFactorRequiredRequest{
+					ActorUUID: userID.(string),
+					Role:      user.Role(p.Args["role"].(string)),
+					Required:  p.Args["required"].(bool),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return h.userSvc.ListTwoFactorRequiredRoles(p.Context)
+			},
+		},
+	)
+
+	typeChangePasswordRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ChangePasswordRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"currentPassword": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"newPassword": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("changePassword",
+		&graphql.Field{
+			Description: "Changes the password and logs out other sessions",
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeChangePasswordRequest),
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				accessToken, _ := p.Context.Value(contextKeyAccessToken).(string)
+				if accessToken == "" {
+					return nil, errors.New("unauthorized request")
+				}
+
+				req := p.Args["request"].(map[string]interface{})
+
+				err := h.userSvc.ChangePassword(p.Context, user.ChangePasswordRequest{
+					AccessToken:     accessToken,
+					CurrentPassword: req["currentPassword"].(string),
+					NewPassword:     req["newPassword"].(string),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("requestPasswordReset",
+		&graphql.Field{
+			Description: "Sends a password reset link to the email, it returns true whether a user has the email or not",
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				err := h.userSvc.RequestPasswordReset(p.Context, user.RequestPasswordResetRequest{
+					Email: p.Args["email"].(string),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	typeResetPasswordRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ResetPasswordRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"token": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Token of the password reset link",
+			},
+			"newPassword": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("resetPassword",
+		&graphql.Field{
+			Description: "Sets the password with a reset token and logs out all sessions",
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeResetPasswordRequest),
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := p.Args["request"].(map[string]interface{})
+
+				err := h.userSvc.ResetPassword(p.Context, user.ResetPasswordRequest{
+					Token:       req["token"].(string),
+					NewPassword: req["newPassword"].(string),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("inviteUser",
+		&graphql.Field{
+			Description: "Sends an invitation link to the email, only admins can invite users",
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"role": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				c := clientFromContext(p.Context)
+
+				err := h.userSvc.InviteUser(p.Context, user.InviteUserRequest{
+					ActorUUID: userID.(string),
+					Email:     p.Args["email"].(string),
+					Role:      user.Role(p.Args["role"].(string)),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	typeAcceptInvitationRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "AcceptInvitationRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"token": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Token of the invitation link",
+			},
+			"username": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"password": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("acceptInvitation",
+		&graphql.Field{
+			Description: "Creates the invited user with a verified email",
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeAcceptInvitationRequest),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				req := p.Args["request"].(map[string]interface{})
+
+				return h.userSvc.AcceptInvitation(p.Context, user.AcceptInvitationRequest{
+					Token:    req["token"].(string),
+					Username: req["username"].(string),
+					Password: req["password"].(string),
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("updateMyEmail",
+		&graphql.Field{
+			Description: "Sets an unverified email and sends a verification link to it",
+			Args: graphql.FieldConfigArgument{
+				"email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				return h.userSvc.UpdateEmail(p.Context, user.UpdateEmailRequest{
+					UserUUID: userID.(string),
+					Email:    p.Args["email"].(string),
+				})
+			},
+		},
+	)
+
+	typeUpdateMyProfileRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateMyProfileRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"displayName": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+			},
+			"bio": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+			},
+			"avatarFileName": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+				Description:  "Name of an uploaded image of the user, empty removes the avatar",
+			},
+			"website": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+			},
+			"socialLinks": &graphql.InputObjectFieldConfig{
+				Type:         graphql.NewList(graphql.NewNonNull(graphql.String)),
+				DefaultValue: []interface{}{},
+				Description:  "Http or https urls of profiles on other sites",
+			},
+		},
+	})
+
+	mutation.AddFieldConfig("updateMyProfile",
+		&graphql.Field{
+			Description: "Replaces the public profile of the authenticated user",
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeUpdateMyProfileRequest),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				req := p.Args["request"].(map[string]interface{})
+
+				inputs, _ := req["socialLinks"].([]interface{})
+
+				socialLinks := make([]string, len(inputs))
+				for i := range inputs {
+					socialLinks[i] = inputs[i].(string)
+				}
+
+				return h.userSvc.UpdateProfile(p.Context, user.UpdateProfileRequest{
+					UserUUID: userID.(string),
+					Profile: user.Profile{
+						DisplayName:    req["displayName"].(string),
+						Bio:            req["bio"].(string),
+						AvatarFileName: req["avatarFileName"].(string),
+						Website:        req["website"].(string),
+						SocialLinks:    socialLinks,
+					},
+				})
+			},
+		},
+	)
+
+	query.AddFieldConfig("userByUsername",
+		&graphql.Field{
+			Description: "Public profile of the user for author pages",
+			Args: graphql.FieldConfigArgument{
+				"username": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.userSvc.GetUserByUsername(p.Context, p.Args["username"].(string))
+			},
+		},
+	)
+
+	userConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "User",
+		NodeType: typeUser,
+	})
+
+	usersArgs := graphql.FieldConfigArgument{
+		"query": &graphql.ArgumentConfig{
+			Type:         graphql.String,
+			DefaultValue: "",
+			Description:  "Matches usernames, emails and display names containing it, case insensitive",
+		},
+	}
+
+	for name, arg := range relay.ConnectionArgs {
+		usersArgs[name] = arg
+	}
+
+	query.AddFieldConfig("users",
+		&graphql.Field{
+			Description: "Users ordered by username, only admins can list users",
+			Type:        userConnectionDefinition.ConnectionType,
+			Args:        usersArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				args := relay.NewConnectionArguments(p.Args)
+
+				res, err := h.userSvc.ListUsers(p.Context, user.ListUsersRequest{
+					ActorUUID: userID.(string),
+					Query:     p.Args["query"].(string),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				data := make([]interface{}, len(res))
+				for i := range res {
+					data[i] = res[i]
+				}
+
+				return relay.ConnectionFromArray(data, args), nil
+			},
+		},
+	)
+
+	typeAuditEvent := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuditEvent",
+		Fields: graphql.Fields{
+			"uuid": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).UUID, nil
+				},
+			},
+			"actorUUID": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null for anonymous callers and the garbage collection",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullString(p.Source.(*audit.Event).ActorUUID), nil
+				},
+			},
+			"actor": &graphql.Field{
+				Type:        typeUser,
+				Description: "Null for anonymous callers, the garbage collection and deleted users",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					actorUUID := p.Source.(*audit.Event).ActorUUID
+					if actorUUID == "" {
+						return nil, nil
+					}
+
+					usr, err := h.userSvc.GetUserByUUID(p.Context, actorUUID)
+					if err != nil {
+						if _, ok := errors.Cause(err).(user.ErrUserWithUUIDNotFound); ok {
+							return nil, nil
+						}
+
+						return nil, err
+					}
+
+					return usr, nil
+				},
+			},
+			"action": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).Action, nil
+				},
+			},
+			"targetType": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).TargetType, nil
+				},
+			},
+			"targetID": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).TargetID, nil
+				},
+			},
+			"ip": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).IP, nil
+				},
+			},
+			"userAgent": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).UserAgent, nil
+				},
+			},
+			"diff": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Json object of changed fields with their previous and new values, like {\"role\":{\"from\":\"user\",\"to\":\"admin\"}}",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					diff := p.Source.(*audit.Event).Diff
+
+					res := make(map[string]map[string]interface{}, len(diff))
+					for name, change := range diff {
+						res[name] = map[string]interface{}{"from": change.From, "to": change.To}
+					}
+
+					b, err := json.Marshal(res)
+					if err != nil {
+						return nil, errors.Wrap(err, "error on marshal diff")
+					}
+
+					return string(b), nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*audit.Event).CreatedAt.Format(time.RFC3339), nil
+				},
+			},
+		},
+	})
+
+	auditEventConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "AuditEvent",
+		NodeType: typeAuditEvent,
+	})
+
+	auditEventsArgs := graphql.FieldConfigArgument{
+		"actorUUID": &graphql.ArgumentConfig{
+			Type:         graphql.String,
+			DefaultValue: "",
+		},
+		"action": &graphql.ArgumentConfig{
+			Type:         graphql.String,
+			DefaultValue: "",
+			Description:  "Name of the action, like user.login or post.publish",
+		},
+		"from": &graphql.ArgumentConfig{
+			Type:         graphql.String,
+			DefaultValue: "",
+			Description:  "Inclusive start of the time range in RFC3339 format",
+		},
+		"to": &graphql.ArgumentConfig{
+			Type:         graphql.String,
+			DefaultValue: "",
+			Description:  "Exclusive end of the time range in RFC3339 format",
+		},
+	}
+
+	for name, arg := range relay.ConnectionArgs {
+		auditEventsArgs[name] = arg
+	}
+
+	query.AddFieldConfig("auditEvents",
+		&graphql.Field{
+			Description: "Audit events newest first, only admins can list audit events. Pages are fetched with first and after.",
+			Type:        auditEventConnectionDefinition.ConnectionType,
+			Args:        auditEventsArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				usr, err := h.userSvc.GetUserByUUID(p.Context, userID.(string))
+				if err != nil {
+					return nil, err
+				}
+
+				from, err := timeFromArg(p.Args["from"].(string))
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid from")
+				}
+
+				to, err := timeFromArg(p.Args["to"].(string))
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid to")
+				}
+
+				args := relay.NewConnectionArguments(p.Args)
+
+				// pages are loaded by keyset, which only goes forward
+				if args.Last >= 0 || args.Before != "" {
+					return nil, errors.New("auditEvents only supports first and after")
+				}
+
+				after, err := auditCursorFromArg(string(args.After))
+				if err != nil {
+					return nil, errors.Wrap(err, "invalid after")
+				}
+
+				res, err := h.auditSvc.ListEvents(p.Context, audit.ListEventsRequest{
+					UserRole: usr.Role,
+					Filter: audit.Filter{
+						ActorUUID: p.Args["actorUUID"].(string),
+						Action:    p.Args["action"].(string),
+						From:      from,
+						To:        to,
+					},
+					First: args.First,
+					After: after,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				conn := relay.NewConnection()
+				conn.PageInfo.HasNextPage = res.HasNextPage
+				conn.PageInfo.HasPreviousPage = after != nil
+
+				for i := range res.Events {
+					conn.Edges = append(conn.Edges, &relay.Edge{Node: res.Events[i], Cursor: auditCursor(res.Events[i])})
+				}
+
+				if len(conn.Edges) > 0 {
+					conn.PageInfo.StartCursor = conn.Edges[0].Cursor
+					conn.PageInfo.EndCursor = conn.Edges[len(conn.Edges)-1].Cursor
+				}
+
+				return conn, nil
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("setUserRole",
+		&graphql.Field{
+			Description: "Changes the role of the user, only admins can change roles of other users",
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"role": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				c := clientFromContext(p.Context)
+
+				return h.userSvc.SetUserRole(p.Context, user.SetUserRoleRequest{
+					ActorUUID: userID.(string),
+					UserUUID:  p.Args["uuid"].(string),
+					Role:      user.Role(p.Args["role"].(string)),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("disableUser",
+		&graphql.Field{
+			Description: "Disables the user and revokes their sessions, only admins can disable other users",
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				c := clientFromContext(p.Context)
+
+				return h.userSvc.DisableUser(p.Context, user.DisableUserRequest{
+					ActorUUID: userID.(string),
+					UserUUID:  p.Args["uuid"].(string),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("enableUser",
+		&graphql.Field{
+			Description: "Enables the disabled user, only admins can enable other users",
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				c := clientFromContext(p.Context)
+
+				return h.userSvc.EnableUser(p.Context, user.EnableUserRequest{
+					ActorUUID: userID.(string),
+					UserUUID:  p.Args["uuid"].(string),
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("deleteUser",
+		&graphql.Field{
+			Description: "Deletes the user, only admins can delete other users",
+			Args: graphql.FieldConfigArgument{
+				"uuid": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"reassignTo": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Uuid of the user who gets posts and files of the deleted user, they are orphaned without it",
+				},
+			},
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				reassignTo, _ := p.Args["reassignTo"].(string)
+				c := clientFromContext(p.Context)
+
+				err := h.userSvc.DeleteUser(p.Context, user.DeleteUserRequest{
+					ActorUUID:  userID.(string),
+					UserUUID:   p.Args["uuid"].(string),
+					ReassignTo: reassignTo,
+					IP:         c.IP,
+					UserAgent:  c.UserAgent,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("requestEmailVerification",
+		&graphql.Field{
+			Description: "Sends the verification link again, it does nothing if the email is verified",
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				err := h.userSvc.RequestEmailVerification(p.Context, userID.(string))
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("verifyEmail",
+		&graphql.Field{
+			Args: graphql.FieldConfigArgument{
+				"token": &graphql.ArgumentConfig{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "Token of the verification link",
+				},
+			},
+			Type: graphql.NewNonNull(typeUser),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return h.userSvc.VerifyEmail(p.Context, user.VerifyEmailRequest{
+					Token: p.Args["token"].(string),
+				})
+			},
+		},
+	)
+
+	typeAPIToken := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ApiToken",
+		Fields: graphql.Fields{
+			"uuid": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(user.APIToken).UUID, nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(user.APIToken).Name, nil
+				},
+			},
+			"scopes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					scopes := p.Source.(user.APIToken).Scopes
+
+					res := make([]string, len(scopes))
+					for i := range scopes {
+						res[i] = string(scopes[i])
+					}
+
+					return res, nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(user.APIToken).CreatedAt.Format(time.RFC3339), nil
+				},
+			},
+			"expiresAt": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null for tokens which don't expire",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullTime(p.Source.(user.APIToken).ExpiresAt), nil
+				},
+			},
+			"lastUsedAt": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Null for unused tokens, it is updated at most once a minute",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nullTime(p.Source.(user.APIToken).LastUsedAt), nil
+				},
+			},
+		},
+	})
+
+	apiTokenConnectionDefinition := relay.ConnectionDefinitions(relay.ConnectionConfig{
+		Name:     "ApiToken",
+		NodeType: typeAPIToken,
+	})
+
+	query.AddFieldConfig("myApiTokens",
+		&graphql.Field{
+			Description: "Api tokens of the authenticated user which aren't revoked, newest first",
+			Type:        apiTokenConnectionDefinition.ConnectionType,
+			Args:        relay.ConnectionArgs,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				args := relay.NewConnectionArguments(p.Args)
+
+				res, err := h.userSvc.ListAPITokens(p.Context, userID.(string))
+				if err != nil {
+					return nil, err
+				}
+
+				data := make([]interface{}, len(res))
+				for i := range res {
+					data[i] = res[i]
+				}
+
+				return relay.ConnectionFromArray(data, args), nil
+			},
+		},
+	)
+
+	typeCreateAPITokenRequest := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateApiTokenRequest",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"scopes": &graphql.InputObjectFieldConfig{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Any of `posts:read`, `posts:write`, `files:read` and `files:write`",
+			},
+			"expiresAt": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "RFC 3339 time, tokens without it don't expire",
+			},
+		},
+	})
+
+	typeCreateAPITokenResponse := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CreateApiTokenResponse",
+		Fields: graphql.Fields{
+			"token": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Returned once, send it as `Authorization: Bearer <token>`",
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					return p.Source.(*user.LogInResponse).AccessToken, nil
+					return p.Source.(*user.CreateAPITokenResponse).Token, nil
 				},
 			},
-			"user": &graphql.Field{
-				Type: graphql.NewNonNull(typeUser),
+			"apiToken": &graphql.Field{
+				Type: graphql.NewNonNull(typeAPIToken),
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					return h.userSvc.GetUserByUUID(p.Context, p.Source.(*user.LogInResponse).UserUUID)
+					return p.Source.(*user.CreateAPITokenResponse).APIToken, nil
 				},
 			},
 		},
 	})
 
-	query.AddFieldConfig("me",
+	mutation.AddFieldConfig("createApiToken",
 		&graphql.Field{
-			Type: graphql.NewNonNull(typeUser),
+			Description: "Creates a scoped token for machine clients, api tokens can't create tokens",
+			Args: graphql.FieldConfigArgument{
+				"request": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeCreateAPITokenRequest),
+				},
+			},
+			Type: graphql.NewNonNull(typeCreateAPITokenResponse),
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 				userID := p.Context.Value(contextKeyUserUUID)
 				if userID == nil {
 					return nil, errors.New("unauthorized request")
 				}
 
-				return h.userSvc.GetUserByUUID(p.Context, userID.(string))
+				req := p.Args["request"].(map[string]interface{})
+
+				scopes := req["scopes"].([]interface{})
+
+				createReq := user.CreateAPITokenRequest{
+					UserUUID: userID.(string),
+					Name:     req["name"].(string),
+					Scopes:   make([]user.Scope, len(scopes)),
+				}
+
+				for i := range scopes {
+					createReq.Scopes[i] = user.Scope(scopes[i].(string))
+				}
+
+				if v, ok := req["expiresAt"].(string); ok {
+					expiresAt, err := time.Parse(time.RFC3339, v)
+					if err != nil {
+						return nil, errors.New("expiresAt should be an RFC 3339 time")
+					}
+
+					createReq.ExpiresAt = expiresAt
+				}
+
+				return h.userSvc.CreateAPIToken(p.Context, createReq)
 			},
 		},
 	)
 
-	mutation.AddFieldConfig("logIn",
+	mutation.AddFieldConfig("revokeApiToken",
 		&graphql.Field{
 			Args: graphql.FieldConfigArgument{
-				"request": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(typeLogInRequest),
+				"uuid": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
 				},
 			},
-			Type: graphql.NewNonNull(typeLogInResponse),
+			Type: graphql.NewNonNull(graphql.Boolean),
 			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-				req := p.Args["request"].(map[string]interface{})
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
 
-				return h.userSvc.LogIn(p.Context, user.LogInRequest{
-					Username: req["username"].(string),
-					Password: req["password"].(string),
+				err := h.userSvc.RevokeAPIToken(p.Context, user.RevokeAPITokenRequest{
+					UserUUID:  userID.(string),
+					TokenUUID: p.Args["uuid"].(string),
 				})
+				if err != nil {
+					return nil, err
+				}
+
+				return true, nil
 			},
 		},
 	)
 
+	typeFocalPointInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "FocalPointInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"x": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Float),
+			},
+			"y": &graphql.InputObjectFieldConfig{
+				Type: graphql.NewNonNull(graphql.Float),
+			},
+		},
+	})
+
+	typeMediaInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "MediaInput",
+		Description: "Reference to an uploaded file by `fileName`, or a new `file` uploaded with the request",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"fileName": &graphql.InputObjectFieldConfig{
+				Type: graphql.String,
+			},
+			"file": &graphql.InputObjectFieldConfig{
+				Type: typeUpload,
+			},
+			"altText": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+			},
+			"caption": &graphql.InputObjectFieldConfig{
+				Type:         graphql.String,
+				DefaultValue: "",
+			},
+			"focalPoint": &graphql.InputObjectFieldConfig{
+				Type: typeFocalPointInput,
+			},
+		},
+	})
+
 	typeCreatePostRequest := graphql.NewInputObject(graphql.InputObjectConfig{
 		Name: "CreatePostRequest",
 		Fields: graphql.InputObjectConfigFieldMap{
@@ -224,6 +1786,13 @@ func (h *handler) newSchema() graphql.Schema {
 			"contentMarkdown": &graphql.InputObjectFieldConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"coverImage": &graphql.InputObjectFieldConfig{
+				Type: typeMediaInput,
+			},
+			"attachments": &graphql.InputObjectFieldConfig{
+				Type:         graphql.NewList(graphql.NewNonNull(typeMediaInput)),
+				DefaultValue: []interface{}{},
+			},
 		},
 	})
 
@@ -243,11 +1812,30 @@ func (h *handler) newSchema() graphql.Schema {
 
 				req := p.Args["request"].(map[string]interface{})
 
-				return h.postSvc.CreatePost(p.Context, post.CreatePostRequest{
+				coverImage, attachments, uploaded, err := h.mediaFromArgs(p.Context, userID.(string), req)
+				if err != nil {
+					return nil, err
+				}
+
+				c := clientFromContext(p.Context)
+
+				res, err := h.postSvc.CreatePost(p.Context, post.CreatePostRequest{
+					UserUUID:        userID.(string),
 					Title:           req["title"].(string),
 					Slug:            req["slug"].(string),
 					ContentMarkdown: req["contentMarkdown"].(string),
+					CoverImage:      coverImage,
+					Attachments:     attachments,
+					IP:              c.IP,
+					UserAgent:       c.UserAgent,
 				})
+				if err != nil {
+					h.deleteUploadedFiles(p.Context, uploaded)
+
+					return nil, err
+				}
+
+				return res, nil
 			},
 		},
 	)
@@ -265,6 +1853,13 @@ func (h *handler) newSchema() graphql.Schema {
 			"contentMarkdown": &graphql.InputObjectFieldConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"coverImage": &graphql.InputObjectFieldConfig{
+				Type: typeMediaInput,
+			},
+			"attachments": &graphql.InputObjectFieldConfig{
+				Type:         graphql.NewList(graphql.NewNonNull(typeMediaInput)),
+				DefaultValue: []interface{}{},
+			},
 		},
 	})
 
@@ -287,11 +1882,30 @@ func (h *handler) newSchema() graphql.Schema {
 
 				req := p.Args["request"].(map[string]interface{})
 
-				return h.postSvc.UpdatePostByUUID(p.Context, p.Args["uuid"].(string), post.UpdatePostByUUIDRequest{
+				coverImage, attachments, uploaded, err := h.mediaFromArgs(p.Context, userID.(string), req)
+				if err != nil {
+					return nil, err
+				}
+
+				c := clientFromContext(p.Context)
+
+				res, err := h.postSvc.UpdatePostByUUID(p.Context, p.Args["uuid"].(string), post.UpdatePostByUUIDRequest{
+					UserUUID:        userID.(string),
 					Title:           req["title"].(string),
 					Slug:            req["slug"].(string),
 					ContentMarkdown: req["contentMarkdown"].(string),
+					CoverImage:      coverImage,
+					Attachments:     attachments,
+					IP:              c.IP,
+					UserAgent:       c.UserAgent,
 				})
+				if err != nil {
+					h.deleteUploadedFiles(p.Context, uploaded)
+
+					return nil, err
+				}
+
+				return res, nil
 			},
 		},
 	)
@@ -310,7 +1924,129 @@ func (h *handler) newSchema() graphql.Schema {
 					return nil, errors.New("unauthorized request")
 				}
 
-				return h.postSvc.PublishPostByUUID(p.Context, p.Args["uuid"].(string))
+				usr, err := h.userSvc.GetUserByUUID(p.Context, userID.(string))
+				if err != nil {
+					return nil, err
+				}
+
+				if !usr.EmailVerified() {
+					return nil, user.ErrEmailNotVerified{}
+				}
+
+				c := clientFromContext(p.Context)
+
+				return h.postSvc.PublishPostByUUID(p.Context, p.Args["uuid"].(string), post.PublishPostByUUIDRequest{
+					UserUUID:  usr.UUID,
+					IP:        c.IP,
+					UserAgent: c.UserAgent,
+				})
+			},
+		},
+	)
+
+	mutation.AddFieldConfig("uploadFile",
+		&graphql.Field{
+			Args: graphql.FieldConfigArgument{
+				"file": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(typeUpload),
+				},
+			},
+			Type: graphql.NewNonNull(typeFile),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				f := p.Args["file"].(*upload)
+
+				return h.uploadGraphQLFile(p.Context, userID.(string), f)
+			},
+		},
+	)
+
+	typeStorageUsage := graphql.NewObject(graphql.ObjectConfig{
+		Name: "StorageUsage",
+		Fields: graphql.Fields{
+			"usedBytes": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*file.GetStorageUsageResponse).Usage.Bytes, nil
+				},
+			},
+			"usedFiles": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*file.GetStorageUsageResponse).Usage.Files, nil
+				},
+			},
+			"maxBytes": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Null means unlimited",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if v := p.Source.(*file.GetStorageUsageResponse).Quota.MaxBytes; v > 0 {
+						return v, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"maxFiles": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Null means unlimited",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if v := p.Source.(*file.GetStorageUsageResponse).Quota.MaxFiles; v > 0 {
+						return v, nil
+					}
+
+					return nil, nil
+				},
+			},
+			"remainingBytes": &graphql.Field{
+				Type:        graphql.Float,
+				Description: "Null means unlimited",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					res := p.Source.(*file.GetStorageUsageResponse)
+					if res.Quota.MaxBytes > 0 {
+						return remaining(res.Quota.MaxBytes, res.Usage.Bytes), nil
+					}
+
+					return nil, nil
+				},
+			},
+			"remainingFiles": &graphql.Field{
+				Type:        graphql.Int,
+				Description: "Null means unlimited",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					res := p.Source.(*file.GetStorageUsageResponse)
+					if res.Quota.MaxFiles > 0 {
+						return remaining(res.Quota.MaxFiles, res.Usage.Files), nil
+					}
+
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	query.AddFieldConfig("myStorageUsage",
+		&graphql.Field{
+			Type: graphql.NewNonNull(typeStorageUsage),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				userID := p.Context.Value(contextKeyUserUUID)
+				if userID == nil {
+					return nil, errors.New("unauthorized request")
+				}
+
+				usr, err := h.userSvc.GetUserByUUID(p.Context, userID.(string))
+				if err != nil {
+					return nil, err
+				}
+
+				return h.fileSvc.GetStorageUsage(p.Context, file.GetStorageUsageRequest{
+					UserUUID: usr.UUID,
+					UserRole: string(usr.Role),
+				})
 			},
 		},
 	)
@@ -407,5 +2143,233 @@ func (h *handler) newSchema() graphql.Schema {
 		panic(errors.Wrap(errors.WithStack(err), "error on new schema"))
 	}
 
+	authorizeScopes(&schema)
+	traceResolvers(&schema)
+
 	return schema
 }
+
+type postFile struct {
+	*file.Entity
+	media post.Media
+}
+
+func fileOf(source interface{}) *file.Entity {
+	switch v := source.(type) {
+	case *postFile:
+		return v.Entity
+	case *file.Entity:
+		return v
+	default:
+		return nil
+	}
+}
+
+func mediaOf(source interface{}) *post.Media {
+	if v, ok := source.(*postFile); ok {
+		return &v.media
+	}
+
+	return nil
+}
+
+func (h *handler) postFile(ctx context.Context, media post.Media) (*postFile, error) {
+	f, err := h.fileSvc.GetFileByName(ctx, media.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postFile{Entity: f, media: media}, nil
+}
+
+// uploadGraphQLFile uploads files of uploadFile and media inputs, so both require the scope
+func (h *handler) uploadGraphQLFile(ctx context.Context, userUUID string, f *upload) (*file.Entity, error) {
+	err := checkScope(ctx, user.ScopeFilesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.uploadFile(ctx, userUUID, f.Header.Header.Get("Content-Type"), f.File)
+	if err != nil {
+		if errors.Is(err, errUploadTooLarge) {
+			return nil, errors.New(h.uploadTooLarge().Detail)
+		}
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type mediaInput struct {
+	media post.Media
+	file  *upload
+}
+
+func mediaFromInput(input map[string]interface{}) (*mediaInput, error) {
+	altText, _ := input["altText"].(string)
+	caption, _ := input["caption"].(string)
+
+	res := mediaInput{
+		media: post.Media{
+			AltText: altText,
+			Caption: caption,
+		},
+	}
+
+	if f, ok := input["file"].(*upload); ok {
+		res.file = f
+	} else if fileName, ok := input["fileName"].(string); ok {
+		res.media.FileName = fileName
+	} else {
+		return nil, errors.New("one of fileName or file is required")
+	}
+
+	if fp, ok := input["focalPoint"].(map[string]interface{}); ok {
+		res.media.FocalPoint = &post.FocalPoint{
+			X: fp["x"].(float64),
+			Y: fp["y"].(float64),
+		}
+	}
+
+	return &res, nil
+}
+
+func (h *handler) mediaFromArgs(ctx context.Context, userUUID string, req map[string]interface{}) (*post.Media, []post.Media, []string, error) {
+	var inputs []*mediaInput
+
+	var coverImage *mediaInput
+
+	if input, ok := req["coverImage"].(map[string]interface{}); ok {
+		m, err := mediaFromInput(input)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		coverImage = m
+		inputs = append(inputs, m)
+	}
+
+	attachmentInputs, _ := req["attachments"].([]interface{})
+
+	for i := range attachmentInputs {
+		input, _ := attachmentInputs[i].(map[string]interface{})
+
+		m, err := mediaFromInput(input)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		inputs = append(inputs, m)
+	}
+
+	uploaded := make([]string, 0)
+
+	for _, m := range inputs {
+		if m.file == nil {
+			continue
+		}
+
+		f, err := h.uploadGraphQLFile(ctx, userUUID, m.file)
+		if err != nil {
+			h.deleteUploadedFiles(ctx, uploaded)
+
+			return nil, nil, nil, err
+		}
+
+		m.media.FileName = f.Name
+		uploaded = append(uploaded, f.Name)
+	}
+
+	var cover *post.Media
+
+	if coverImage != nil {
+		cover = &coverImage.media
+		inputs = inputs[1:]
+	}
+
+	attachments := make([]post.Media, len(inputs))
+	for i := range inputs {
+		attachments[i] = inputs[i].media
+	}
+
+	return cover, attachments, uploaded, nil
+}
+
+// deleteUploadedFiles ignores errors, the garbage collection removes orphaned files
+func (h *handler) deleteUploadedFiles(ctx context.Context, fileNames []string) {
+	for i := range fileNames {
+		_ = h.fileSvc.DeleteFile(ctx, fileNames[i])
+	}
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func auditCursor(event *audit.Event) relay.ConnectionCursor {
+	s := event.CreatedAt.Format(time.RFC3339Nano) + " " + event.UUID
+
+	return relay.ConnectionCursor(base64.RawURLEncoding.EncodeToString([]byte(s)))
+}
+
+func auditCursorFromArg(s string) (*audit.Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on decode cursor")
+	}
+
+	parts := strings.SplitN(string(b), " ", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errors.Wrap(errors.WithStack(err), "error on parse cursor time")
+	}
+
+	return &audit.Cursor{CreatedAt: createdAt, UUID: parts[1]}, nil
+}
+
+func timeFromArg(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func remaining(max, used int64) int64 {
+	if used > max {
+		return 0
+	}
+
+	return max - used
+}
+
+func privateFieldsVisible(ctx context.Context, usr *user.Entity) bool {
+	if userID, _ := ctx.Value(contextKeyUserUUID).(string); userID == usr.UUID {
+		return true
+	}
+
+	role, _ := ctx.Value(contextKeyUserRole).(user.Role)
+
+	return role == user.RoleAdmin
+}
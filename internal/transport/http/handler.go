@@ -2,9 +2,15 @@ package http
 
 import (
 	"github.com/gorilla/mux"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/oidc"
+	"github.com/nasermirzaei89/api/internal/services/audit"
 	"github.com/nasermirzaei89/api/internal/services/file"
+	"github.com/nasermirzaei89/api/internal/services/health"
 	"github.com/nasermirzaei89/api/internal/services/post"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
 	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/prometheus/client_golang/prometheus"
 	"net/http"
 )
 
@@ -13,43 +19,101 @@ type handler struct {
 	userSvc                 user.Service
 	postSvc                 post.Service
 	fileSvc                 file.Service
+	healthSvc               health.Service
+	auditSvc                audit.Service
 	enableGraphQLPretty     bool
 	enableGraphQLPlayground bool
 	enableGraphiQL          bool
 	gzipLevel               int
-	logger                  loggerInterface
+	maxUploadSize           int64
+	logger                  logger.Logger
+	logBody                 bool
+	logRedactedHeaders      []string
+	logRedactedFields       []string
+	metrics                 *metrics
+	metricsHandler          http.Handler
+	rateLimitSvc            ratelimit.Service
+	rateLimitPolicies       map[string]ratelimit.Policy
+	rateLimiter             *rateLimiter
+	trustProxyHeaders       bool
+	oidcProviders           map[string]*oidc.Provider
+	oidcClientURL           string
+	allowedOrigins          []string
+	enableSessionCookies    bool
+	sessionCookieDomain     string
+	sessionCookies          *sessionCookies
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.router.ServeHTTP(w, r)
 }
 
-func NewHandler(l loggerInterface, userSvc user.Service, postSvc post.Service, fileSvc file.Service, options ...Option) http.Handler {
+func NewHandler(l logger.Logger, userSvc user.Service, postSvc post.Service, fileSvc file.Service, healthSvc health.Service, auditSvc audit.Service, options ...Option) http.Handler {
 	h := handler{
-		router:  mux.NewRouter(),
-		userSvc: userSvc,
-		postSvc: postSvc,
-		fileSvc: fileSvc,
-		logger:  l,
+		router:    mux.NewRouter(),
+		userSvc:   userSvc,
+		postSvc:   postSvc,
+		fileSvc:   fileSvc,
+		healthSvc: healthSvc,
+		auditSvc:  auditSvc,
+		logger:    l,
 	}
 
+	h.logRedactedHeaders = append(h.logRedactedHeaders, defaultRedactedHeaders...)
+	h.logRedactedFields = append(h.logRedactedFields, defaultRedactedFields...)
+
 	for i := range options {
 		options[i](&h)
 	}
 
-	h.router.Use(cors())
+	if h.rateLimitSvc != nil {
+		h.rateLimiter = &rateLimiter{
+			svc:      h.rateLimitSvc,
+			policies: h.rateLimitPolicies,
+		}
+	}
+
+	if h.enableSessionCookies {
+		h.sessionCookies = newSessionCookies(h.sessionCookieDomain, h.allowedOrigins, h.trustProxyHeaders)
+	}
+
+	h.router.Use(traceRequests())
+	h.router.Use(requestID())
+	h.router.Use(identifyClient(h.trustProxyHeaders))
+	h.router.Use(instrument(h.metrics))
+	h.router.Use(cors(h.allowedOrigins))
 	h.router.Use(gzip(h.gzipLevel))
-	h.router.Use(logger(h.logger))
+	h.router.Use(logRequests(h.logger, h.logBody, newRedactor(h.logRedactedHeaders, h.logRedactedFields)))
 	h.router.Use(recoverPanic())
-	h.router.Use(authenticate(h.userSvc))
+	h.router.Use(authenticate(h.userSvc, h.sessionCookies))
+	h.router.Use(limitRate(h.rateLimiter))
 
 	h.router.Path("/graphql").Handler(h.handleGraphQL(h.enableGraphQLPretty, h.enableGraphiQL, h.enableGraphQLPlayground))
 	h.router.Methods(http.MethodPost).Path("/files").HandlerFunc(h.handleUploadFile())
 	h.router.Methods(http.MethodGet).Path("/files/{fileName}").HandlerFunc(h.handleDownloadFile())
+	h.router.Methods(http.MethodGet).Path("/auth/oidc/{provider}/start").HandlerFunc(h.handleOIDCStart())
+	h.router.Methods(http.MethodGet).Path("/auth/oidc/{provider}/callback").HandlerFunc(h.handleOIDCCallback())
+	h.router.Methods(http.MethodGet).Path("/.well-known/jwks.json").HandlerFunc(h.handleJWKS())
+	h.router.Methods(http.MethodGet).Path("/healthz").HandlerFunc(h.handleLiveness())
+	h.router.Methods(http.MethodGet).Path("/readyz").HandlerFunc(h.handleReadiness())
+
+	if h.metricsHandler != nil {
+		h.router.Methods(http.MethodGet).Path("/metrics").Handler(h.metricsHandler)
+	}
 
 	return &h
 }
 
+func routeTemplate(r *http.Request) string {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+
+	return r.URL.Path
+}
+
 type Option func(h *handler)
 
 func SetGZipLevel(v int) Option {
@@ -75,3 +139,74 @@ func SetGraphQLPlayground(v bool) Option {
 		h.enableGraphQLPlayground = v
 	}
 }
+
+func SetMaxUploadSize(v int64) Option {
+	return func(h *handler) {
+		h.maxUploadSize = v
+	}
+}
+
+func SetLogBody(v bool) Option {
+	return func(h *handler) {
+		h.logBody = v
+	}
+}
+
+func AddLogRedactedHeaders(v ...string) Option {
+	return func(h *handler) {
+		h.logRedactedHeaders = append(h.logRedactedHeaders, v...)
+	}
+}
+
+func AddLogRedactedFields(v ...string) Option {
+	return func(h *handler) {
+		h.logRedactedFields = append(h.logRedactedFields, v...)
+	}
+}
+
+func SetMetrics(reg prometheus.Registerer) Option {
+	return func(h *handler) {
+		h.metrics = newMetrics(reg)
+	}
+}
+
+func SetMetricsHandler(v http.Handler) Option {
+	return func(h *handler) {
+		h.metricsHandler = v
+	}
+}
+
+// SetRateLimit policies are keyed like `POST /files`, `graphql:logIn` or `default`
+func SetRateLimit(svc ratelimit.Service, policies map[string]ratelimit.Policy) Option {
+	return func(h *handler) {
+		h.rateLimitSvc = svc
+		h.rateLimitPolicies = policies
+	}
+}
+
+// SetTrustProxyHeaders must only be enabled behind a proxy setting X-Forwarded-For
+func SetTrustProxyHeaders(v bool) Option {
+	return func(h *handler) {
+		h.trustProxyHeaders = v
+	}
+}
+
+func SetOIDC(providers map[string]*oidc.Provider, clientURL string) Option {
+	return func(h *handler) {
+		h.oidcProviders = providers
+		h.oidcClientURL = clientURL
+	}
+}
+
+func SetAllowedOrigins(v []string) Option {
+	return func(h *handler) {
+		h.allowedOrigins = v
+	}
+}
+
+func SetSessionCookies(enabled bool, domain string) Option {
+	return func(h *handler) {
+		h.enableSessionCookies = enabled
+		h.sessionCookieDomain = domain
+	}
+}
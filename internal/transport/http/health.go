@@ -0,0 +1,78 @@
+package http
+
+import (
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/health"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+type healthCheckResponse struct {
+	Status     health.Status `json:"status"`
+	DurationMS float64       `json:"durationMS"`
+}
+
+type readinessResponse struct {
+	Status string                         `json:"status"`
+	Checks map[string]healthCheckResponse `json:"checks"`
+}
+
+func (rsp readinessResponse) StatusCode() int {
+	if rsp.Status != "ready" {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusOK
+}
+
+func (h *handler) handleLiveness() http.HandlerFunc {
+	type Response struct {
+		Status string `json:"status"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, Response{Status: "ok"})
+	}
+}
+
+// errors of checks may have addresses of dependencies, so they are only logged
+func (h *handler) handleReadiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.healthSvc.CheckReadiness(r.Context())
+		if err != nil {
+			respond(w, r, internalServerError(errors.Wrap(err, "error on check readiness")))
+			return
+		}
+
+		rsp := readinessResponse{
+			Status: "ready",
+			Checks: make(map[string]healthCheckResponse, len(res.Checks)),
+		}
+
+		switch {
+		case res.ShuttingDown:
+			rsp.Status = "shutting down"
+		case !res.Ready:
+			rsp.Status = "not ready"
+		}
+
+		checkErrors := make(map[string]string)
+
+		for name, check := range res.Checks {
+			rsp.Checks[name] = healthCheckResponse{
+				Status:     check.Status,
+				DurationMS: float64(check.Duration.Microseconds()) / 1000,
+			}
+
+			if check.Error != "" {
+				checkErrors[name] = check.Error
+			}
+		}
+
+		if len(checkErrors) > 0 {
+			addLogFields(r.Context(), logger.Fields{"healthCheckErrors": checkErrors})
+		}
+
+		respond(w, r, rsp)
+	}
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// jwksMaxAge is how long verifiers may cache keys, generated keys should be promoted after it
+const jwksMaxAge = 5 * time.Minute
+
+func (h *handler) handleJWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.userSvc.GetJWKS(r.Context())
+		if err != nil {
+			respond(w, r, internalServerError(errors.Wrap(err, "error on get jwks")))
+			return
+		}
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge/time.Second)))
+		respond(w, r, res)
+	}
+}
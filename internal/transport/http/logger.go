@@ -2,30 +2,53 @@ package http
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"github.com/gorilla/mux"
-	"github.com/logrusorgru/aurora"
-	"github.com/pkg/errors"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"io/ioutil"
+	"mime"
 	"net/http"
-	"net/http/httputil"
-	"strings"
+	"sync"
 	"time"
 )
 
-type loggerInterface interface {
-	Println(v ...interface{})
+const contextKeyLogEntry contextKey = "logEntry"
+
+type logEntry struct {
+	mu     sync.Mutex
+	fields logger.Fields
+}
+
+func addLogFields(ctx context.Context, fields logger.Fields) {
+	entry, ok := ctx.Value(contextKeyLogEntry).(*logEntry)
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	for k, v := range fields {
+		entry.fields[k] = v
+	}
 }
 
 type loggerMW struct {
-	next   http.Handler
-	logger loggerInterface
+	next     http.Handler
+	logger   logger.Logger
+	dumpBody bool
+	redactor *redactor
 }
 
-func logger(logger loggerInterface) mux.MiddlewareFunc {
+func logRequests(l logger.Logger, dumpBody bool, rd *redactor) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return &loggerMW{
-			next:   next,
-			logger: logger,
+			next:     next,
+			logger:   l,
+			dumpBody: dumpBody,
+			redactor: rd,
 		}
 	}
 }
@@ -36,8 +59,10 @@ func dumpBody(ctp string) bool {
 		"application/problem+json",
 	}
 
+	mediaType, _, _ := mime.ParseMediaType(ctp)
+
 	for i := range allowedTypes {
-		if allowedTypes[i] == ctp {
+		if allowedTypes[i] == mediaType {
 			return true
 		}
 	}
@@ -48,48 +73,80 @@ func dumpBody(ctp string) bool {
 func (mw *loggerMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
+	entry := &logEntry{fields: logger.Fields{}}
+	r = r.WithContext(context.WithValue(r.Context(), contextKeyLogEntry, entry))
+
 	// ignore body if is file
-	reqDump, err := httputil.DumpRequest(r, dumpBody(r.Header.Get("Content-Type")))
-	if err != nil {
-		mw.logger.Println(errors.Wrap(errors.WithStack(err), "error dumping request"))
-	} else {
-		reqParts := strings.Split(string(reqDump), "\n")
-		req := ""
-		for i := range reqParts {
-			req += fmt.Sprintf("%s\n", aurora.BrightCyan(reqParts[i]))
+	var reqBody []byte
+	if mw.dumpBody && r.Body != nil && dumpBody(r.Header.Get("Content-Type")) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			addLogFields(r.Context(), logger.Fields{"dumpError": err.Error()})
 		}
-		mw.logger.Println(req)
+
+		reqBody = body
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
 	}
 
-	crw := &customRW{rw: w, statusCode: http.StatusOK, body: bytes.NewBufferString("")}
+	crw := &customRW{rw: w, statusCode: http.StatusOK, body: bytes.NewBufferString(""), keepBody: mw.dumpBody}
 	w = crw
 
 	mw.next.ServeHTTP(w, r)
 
-	res := aurora.Cyan(fmt.Sprintf("%s %s\n\n", r.Method, r.RequestURI)).String()
-	res += aurora.Cyan(fmt.Sprintf("%s %d %s\n", r.Proto, crw.statusCode, http.StatusText(crw.statusCode))).String()
-	for k, vv := range w.Header() {
-		for i := range vv {
-			res += aurora.Cyan(fmt.Sprintf("%s: %s\n", k, vv[i])).String()
+	elapsed := time.Since(start)
+
+	fields := logger.Fields{
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"status":     crw.statusCode,
+		"duration":   elapsed.String(),
+		"durationMS": float64(elapsed.Microseconds()) / 1000,
+		"bytes":      crw.size,
+		"remoteAddr": r.RemoteAddr,
+		"userAgent":  r.UserAgent(),
+		"requestID":  requestid.FromContext(r.Context()),
+	}
+
+	if traceID := tracing.TraceID(r.Context()); traceID != "" {
+		fields["traceID"] = traceID
+	}
+
+	if mw.dumpBody {
+		fields["requestHeaders"] = mw.redactor.Headers(r.Header)
+		fields["responseHeaders"] = mw.redactor.Headers(w.Header())
+
+		if len(reqBody) > 0 {
+			fields["requestBody"] = mw.redactor.JSON(reqBody)
+		}
+
+		if crw.body.Len() > 0 {
+			fields["responseBody"] = mw.redactor.JSON(crw.body.Bytes())
 		}
 	}
-	if dumpBody(w.Header().Get("Content-Type")) {
-		res += fmt.Sprintf("\n%s\n\n", aurora.Cyan(crw.body.String()))
+
+	entry.mu.Lock()
+	for k, v := range entry.fields {
+		fields[k] = v
 	}
-	res += aurora.Cyan(fmt.Sprintf(
-		"Response code: %d (%s); Time: %s; Content length: %d bytes",
-		crw.statusCode,
-		http.StatusText(crw.statusCode),
-		time.Since(start),
-		crw.body.Len(),
-	)).String()
-	mw.logger.Println(res)
+	entry.mu.Unlock()
+
+	level := logger.LevelInfo
+	switch {
+	case crw.statusCode >= http.StatusInternalServerError:
+		level = logger.LevelError
+	case crw.statusCode >= http.StatusBadRequest:
+		level = logger.LevelWarn
+	}
+
+	mw.logger.Log(level, "request", fields)
 }
 
 type customRW struct {
 	rw         http.ResponseWriter
 	statusCode int
+	size       int
 	body       *bytes.Buffer
+	keepBody   bool
 }
 
 func (crw *customRW) Header() http.Header {
@@ -97,8 +154,14 @@ func (crw *customRW) Header() http.Header {
 }
 
 func (crw *customRW) Write(i []byte) (int, error) {
-	crw.body.Write(i)
-	return crw.rw.Write(i)
+	if crw.keepBody && dumpBody(crw.rw.Header().Get("Content-Type")) {
+		crw.body.Write(i)
+	}
+
+	n, err := crw.rw.Write(i)
+	crw.size += n
+
+	return n, err
 }
 
 func (crw *customRW) WriteHeader(statusCode int) {
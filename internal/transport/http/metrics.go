@@ -0,0 +1,230 @@
+package http
+
+import (
+	"bytes"
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const metricsNamespace = "api"
+
+type metrics struct {
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+	graphQLOperations   *prometheus.CounterVec
+	graphQLDuration     *prometheus.HistogramVec
+	graphQLErrors       *prometheus.CounterVec
+	fileUploads         *prometheus.CounterVec
+	fileUploadBytes     prometheus.Histogram
+	fileUploadDuration  prometheus.Histogram
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := metrics{
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Total number of http requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of http requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		graphQLOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "operations_total",
+			Help:      "Total number of graphql operations by root field and type.",
+		}, []string{"field", "type"}),
+		graphQLDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of graphql operations by root field and type.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"field", "type"}),
+		graphQLErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "graphql",
+			Name:      "errors_total",
+			Help:      "Total number of errors returned by graphql resolvers by root field and type.",
+		}, []string{"field", "type"}),
+		fileUploads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "file",
+			Name:      "uploads_total",
+			Help:      "Total number of file uploads by result.",
+		}, []string{"result"}),
+		fileUploadBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "file",
+			Name:      "upload_bytes",
+			Help:      "Size of uploaded files.",
+			Buckets:   prometheus.ExponentialBuckets(1<<10, 4, 10),
+		}),
+		fileUploadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "file",
+			Name:      "upload_duration_seconds",
+			Help:      "Duration of file uploads including processing and storing.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+
+	reg.MustRegister(
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.graphQLOperations,
+		m.graphQLDuration,
+		m.graphQLErrors,
+		m.fileUploads,
+		m.fileUploadBytes,
+		m.fileUploadDuration,
+	)
+
+	return &m
+}
+
+func (m *metrics) observeGraphQL(field, typ string, errors int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.graphQLOperations.WithLabelValues(field, typ).Inc()
+	m.graphQLDuration.WithLabelValues(field, typ).Observe(elapsed.Seconds())
+
+	if errors > 0 {
+		m.graphQLErrors.WithLabelValues(field, typ).Add(float64(errors))
+	}
+}
+
+func (m *metrics) observeUpload(size int64, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	if err != nil {
+		m.fileUploads.WithLabelValues("error").Inc()
+		return
+	}
+
+	m.fileUploads.WithLabelValues("success").Inc()
+	m.fileUploadBytes.Observe(float64(size))
+	m.fileUploadDuration.Observe(elapsed.Seconds())
+}
+
+func graphQLOperation(query, operationName string) (string, string) {
+	_, op, ok := operationDefinition(query, operationName)
+	if !ok {
+		return "unknown", "unknown"
+	}
+
+	if op.Name == nil || op.Name.Value == "" {
+		return "anonymous", op.Operation
+	}
+
+	return op.Name.Value, op.Operation
+}
+
+// graphQLRootField labels operations by root field, since operation names are chosen by clients
+func graphQLRootField(schema *graphql.Schema, query, operationName string) string {
+	doc, op, ok := operationDefinition(query, operationName)
+	if !ok {
+		return "other"
+	}
+
+	var root *graphql.Object
+
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	}
+
+	if root == nil {
+		return "other"
+	}
+
+	var res string
+
+	for _, name := range rootFieldNames(doc, op.SelectionSet, make(map[string]bool)) {
+		if _, ok := root.Fields()[name]; !ok {
+			return "other"
+		}
+
+		if res != "" && res != name {
+			return "multiple"
+		}
+
+		res = name
+	}
+
+	if res == "" {
+		return "other"
+	}
+
+	return res
+}
+
+func operationDefinition(query, operationName string) (*ast.Document, *ast.OperationDefinition, bool) {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil, nil, false
+	}
+
+	for i := range doc.Definitions {
+		op, ok := doc.Definitions[i].(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return doc, op, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+type metricsMW struct {
+	next    http.Handler
+	metrics *metrics
+}
+
+func instrument(m *metrics) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if m == nil {
+			return next
+		}
+
+		return &metricsMW{
+			next:    next,
+			metrics: m,
+		}
+	}
+}
+
+func (mw *metricsMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	crw := &customRW{rw: w, statusCode: http.StatusOK, body: new(bytes.Buffer)}
+
+	mw.next.ServeHTTP(crw, r)
+
+	route := routeTemplate(r)
+	status := strconv.Itoa(crw.statusCode)
+
+	mw.metrics.httpRequests.WithLabelValues(route, r.Method, status).Inc()
+	mw.metrics.httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,227 @@
+package http
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const multipartMaxMemory = 32 << 20
+
+// multipartOverhead allows the operations and map fields besides the file
+const multipartOverhead = 1 << 20
+
+// upload is an Upload scalar of https://github.com/jaydenseric/graphql-multipart-request-spec
+type upload struct {
+	File   multipart.File
+	Header *multipart.FileHeader
+}
+
+var typeUpload = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "The `Upload` scalar type represents a file upload.",
+	Serialize: func(value interface{}) interface{} {
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if v, ok := value.(*upload); ok {
+			return v
+		}
+
+		return nil
+	},
+	// uploads are only sent as variables, literals fail the validation
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return nil
+	},
+})
+
+func isMultipartRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return r.Method == http.MethodPost && mediaType == "multipart/form-data"
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func (h *handler) handleGraphQLMultipart(schema *graphql.Schema, pretty bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body *countingReader
+
+		if h.maxUploadSize > 0 {
+			limit := h.maxUploadSize + multipartOverhead
+
+			if r.ContentLength > limit {
+				respond(w, r, h.uploadTooLarge())
+				return
+			}
+
+			body = &countingReader{ReadCloser: http.MaxBytesReader(w, r.Body, limit)}
+			r.Body = body
+		}
+
+		err := r.ParseMultipartForm(multipartMaxMemory)
+		if err != nil {
+			// chunked bodies and wrong content lengths hit the limit while parsing
+			if body != nil && body.n >= h.maxUploadSize+multipartOverhead {
+				respond(w, r, h.uploadTooLarge())
+				return
+			}
+
+			respond(w, r, badRequest("invalid multipart request"))
+			return
+		}
+
+		defer func() {
+			_ = r.MultipartForm.RemoveAll()
+		}()
+
+		var req graphQLRequest
+
+		err = json.Unmarshal([]byte(r.FormValue("operations")), &req)
+		if err != nil {
+			respond(w, r, badRequest("invalid operations field"))
+			return
+		}
+
+		var fileMap map[string][]string
+
+		err = json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+		if err != nil {
+			respond(w, r, badRequest("invalid map field"))
+			return
+		}
+
+		if !h.rateLimiter.allowGraphQL(w, r, req.Query, req.OperationName) {
+			return
+		}
+
+		if req.Variables == nil {
+			req.Variables = make(map[string]interface{})
+		}
+
+		for key, paths := range fileMap {
+			f, fh, err := r.FormFile(key)
+			if err != nil {
+				respond(w, r, badRequest("file '"+key+"' is missing"))
+				return
+			}
+
+			defer func() {
+				_ = f.Close()
+			}()
+
+			if h.maxUploadSize > 0 && fh.Size > h.maxUploadSize {
+				respond(w, r, h.uploadTooLarge())
+				return
+			}
+
+			for i := range paths {
+				err = setVariable(req.Variables, paths[i], &upload{File: f, Header: fh})
+				if err != nil {
+					respond(w, r, badRequest(err.Error()))
+					return
+				}
+			}
+		}
+
+		start := time.Now()
+
+		ctx, span := startGraphQLSpan(r.Context())
+
+		res := graphql.Do(graphql.Params{
+			Schema:         *schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        ctx,
+		})
+
+		name, typ := graphQLOperation(req.Query, req.OperationName)
+		h.metrics.observeGraphQL(graphQLRootField(schema, req.Query, req.OperationName), typ, len(res.Errors), time.Since(start))
+		endGraphQLSpan(span, name, typ, res)
+
+		if len(res.Errors) > 0 {
+			format := formatGraphQLError(r.Context())
+			for i := range res.Errors {
+				res.Errors[i] = format(res.Errors[i].OriginalError())
+			}
+		}
+
+		var buf []byte
+		if pretty {
+			buf, _ = json.MarshalIndent(res, "", "\t")
+		} else {
+			buf, _ = json.Marshal(res)
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf)
+	}
+}
+
+// setVariable replaces the value at an object path like `variables.files.0` with the upload
+func setVariable(variables map[string]interface{}, path string, value *upload) error {
+	parts := strings.Split(path, ".")
+	if len(parts) < 2 || parts[0] != "variables" {
+		return errors.Errorf("invalid object path '%s'", path)
+	}
+
+	var current interface{} = variables
+	for i := 1; i < len(parts); i++ {
+		last := i == len(parts)-1
+
+		switch c := current.(type) {
+		case map[string]interface{}:
+			if last {
+				c[parts[i]] = value
+				return nil
+			}
+
+			current = c[parts[i]]
+		case []interface{}:
+			index, err := strconv.Atoi(parts[i])
+			if err != nil || index < 0 || index >= len(c) {
+				return errors.Errorf("invalid object path '%s'", path)
+			}
+
+			if last {
+				c[index] = value
+				return nil
+			}
+
+			current = c[index]
+		default:
+			return errors.Errorf("invalid object path '%s'", path)
+		}
+	}
+
+	return nil
+}
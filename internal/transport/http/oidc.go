@@ -0,0 +1,203 @@
+package http
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/oidc"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// oidcState binds the callback to the browser which started the login, so logins can't be forged
+type oidcState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+func newOIDCState() (*oidcState, error) {
+	var res oidcState
+	var err error
+
+	for _, v := range []*string{&res.State, &res.Nonce, &res.CodeVerifier} {
+		*v, err = oidc.RandomString()
+		if err != nil {
+			return nil, errors.Wrap(err, "error on random string")
+		}
+	}
+
+	return &res, nil
+}
+
+func (s oidcState) String() string {
+	return s.State + "." + s.Nonce + "." + s.CodeVerifier
+}
+
+func parseOIDCState(v string) (*oidcState, bool) {
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	return &oidcState{State: parts[0], Nonce: parts[1], CodeVerifier: parts[2]}, true
+}
+
+func (h *handler) oidcStateCookie(r *http.Request, provider, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     "oidc_state",
+		Value:    value,
+		Path:     "/auth/oidc/" + provider + "/callback",
+		MaxAge:   maxAge,
+		Secure:   isHTTPS(r, h.trustProxyHeaders),
+		HttpOnly: true,
+		// the callback is a cross site navigation from the provider, so strict cookies wouldn't be sent
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (h *handler) handleOIDCStart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["provider"]
+
+		provider, ok := h.oidcProviders[name]
+		if !ok {
+			respond(w, r, notFound(fmt.Sprintf("unknown provider '%s'", name)))
+			return
+		}
+
+		state, err := newOIDCState()
+		if err != nil {
+			respond(w, r, internalServerError(errors.Wrap(err, "error on new oidc state")))
+			return
+		}
+
+		authURL, err := provider.AuthCodeURL(r.Context(), state.State, state.Nonce, state.CodeVerifier)
+		if err != nil {
+			respond(w, r, internalServerError(errors.Wrap(err, "error on get auth code url")))
+			return
+		}
+
+		http.SetCookie(w, h.oidcStateCookie(r, name, state.String(), int(oidcStateTTL/time.Second)))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+func (h *handler) handleOIDCCallback() http.HandlerFunc {
+	type Response struct {
+		AccessToken                 string `json:"accessToken,omitempty"`
+		UserUUID                    string `json:"userUUID"`
+		ChallengeToken              string `json:"challengeToken,omitempty"`
+		TwoFactorEnrollmentRequired bool   `json:"twoFactorEnrollmentRequired,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["provider"]
+
+		provider, ok := h.oidcProviders[name]
+		if !ok {
+			respond(w, r, notFound(fmt.Sprintf("unknown provider '%s'", name)))
+			return
+		}
+
+		// the state is used once
+		cookie, err := r.Cookie("oidc_state")
+		http.SetCookie(w, h.oidcStateCookie(r, name, "", -1))
+
+		if err != nil {
+			respond(w, r, badRequest("login state is missing or expired, start the login again"))
+			return
+		}
+
+		state, ok := parseOIDCState(cookie.Value)
+		query := r.URL.Query()
+
+		if !ok || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+			respond(w, r, badRequest("invalid login state, start the login again"))
+			return
+		}
+
+		if v := query.Get("error"); v != "" {
+			respond(w, r, unauthorized(fmt.Sprintf("provider responded with error '%s'", v),
+				setExtension("errorDescription", query.Get("error_description"))))
+			return
+		}
+
+		claims, err := provider.Exchange(r.Context(), query.Get("code"), state.CodeVerifier, state.Nonce)
+		if err != nil {
+			addLogFields(r.Context(), logger.Fields{"error": err.Error()})
+			respond(w, r, unauthorized("error on verify the login with the provider"))
+			return
+		}
+
+		c := clientFromContext(r.Context())
+
+		res, err := h.userSvc.LogInWithOIDC(r.Context(), user.LogInWithOIDCRequest{
+			Provider:          name,
+			Subject:           claims.Subject,
+			Email:             claims.Email,
+			EmailVerified:     claims.EmailVerified,
+			PreferredUsername: claims.PreferredUsername,
+			IP:                c.IP,
+			UserAgent:         c.UserAgent,
+		})
+		if err != nil {
+			switch err := errors.Cause(err).(type) {
+			case user.ErrOIDCUserNotFound:
+				respond(w, r, forbidden(err.Error()))
+			case user.ErrUserDisabled:
+				respond(w, r, forbidden(err.Error()))
+			case user.ErrEmailAlreadyUsed:
+				respond(w, r, conflict(err.Error()))
+			default:
+				respond(w, r, internalServerError(errors.Wrap(err, "error on log in with oidc")))
+			}
+
+			return
+		}
+
+		rsp := Response{
+			AccessToken:                 res.AccessToken,
+			UserUUID:                    res.UserUUID,
+			ChallengeToken:              res.ChallengeToken,
+			TwoFactorEnrollmentRequired: res.TwoFactorEnrollmentRequired,
+		}
+
+		if h.oidcClientURL == "" {
+			respond(w, r, rsp)
+			return
+		}
+
+		if h.sessionCookies != nil && rsp.AccessToken != "" {
+			err = h.sessionCookies.set(w, r, rsp.AccessToken)
+			if err != nil {
+				respond(w, r, internalServerError(errors.Wrap(err, "error on set session cookies")))
+				return
+			}
+
+			rsp.AccessToken = ""
+		}
+
+		// tokens are passed in the fragment, so they aren't sent to servers or logged
+		fragment := url.Values{}
+		fragment.Set("userUUID", rsp.UserUUID)
+
+		if rsp.AccessToken != "" {
+			fragment.Set("accessToken", rsp.AccessToken)
+		}
+
+		if rsp.ChallengeToken != "" {
+			fragment.Set("challengeToken", rsp.ChallengeToken)
+			fragment.Set("twoFactorEnrollmentRequired", fmt.Sprint(rsp.TwoFactorEnrollmentRequired))
+		}
+
+		http.Redirect(w, r, strings.SplitN(h.oidcClientURL, "#", 2)[0]+"#"+fragment.Encode(), http.StatusFound)
+	}
+}
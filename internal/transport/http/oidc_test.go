@@ -0,0 +1,338 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/oidc"
+	"github.com/nasermirzaei89/api/internal/oidc/oidctest"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// the user service runs on in-memory repositories, methods which logins with a provider don't call are left nil
+
+type memUsers struct {
+	user.Repository
+	users map[string]user.Entity
+}
+
+func (repo *memUsers) Insert(ctx context.Context, entity user.Entity) error {
+	repo.users[entity.UUID] = entity
+	return nil
+}
+
+func (repo *memUsers) FindByUUID(ctx context.Context, uuid string) (*user.Entity, error) {
+	if entity, ok := repo.users[uuid]; ok {
+		return &entity, nil
+	}
+
+	return nil, nil
+}
+
+func (repo *memUsers) FindByUsername(ctx context.Context, username string) (*user.Entity, error) {
+	for _, entity := range repo.users {
+		if entity.Username == username {
+			return &entity, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (repo *memUsers) FindByEmail(ctx context.Context, email string) (*user.Entity, error) {
+	for _, entity := range repo.users {
+		if entity.Email == email {
+			return &entity, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type memIdentities struct {
+	user.IdentityRepository
+	identities []user.Identity
+}
+
+func (repo *memIdentities) Insert(ctx context.Context, identity user.Identity) error {
+	repo.identities = append(repo.identities, identity)
+	return nil
+}
+
+func (repo *memIdentities) FindByProviderAndSubject(ctx context.Context, provider, subject string) (*user.Identity, error) {
+	for i := range repo.identities {
+		if repo.identities[i].Provider == provider && repo.identities[i].Subject == subject {
+			return &repo.identities[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+type memSessions struct {
+	user.SessionRepository
+}
+
+func (repo memSessions) Insert(ctx context.Context, session user.Session) error {
+	return nil
+}
+
+type memTwoFactors struct {
+	user.TwoFactorRepository
+}
+
+func (repo memTwoFactors) FindByUserUUID(ctx context.Context, userUUID string) (*user.TwoFactor, error) {
+	return nil, nil
+}
+
+type memTwoFactorRequirements struct {
+	user.TwoFactorRequirementRepository
+}
+
+func (repo memTwoFactorRequirements) ListRequiredRoles(ctx context.Context) ([]user.Role, error) {
+	return nil, nil
+}
+
+type memSigningKeys struct {
+	user.SigningKeyRepository
+}
+
+func (repo memSigningKeys) List(ctx context.Context) ([]user.SigningKey, error) {
+	return nil, nil
+}
+
+func testKeyPair(t *testing.T) ([]byte, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+}
+
+type oidcTest struct {
+	provider *oidctest.Server
+	api      *httptest.Server
+	users    *memUsers
+}
+
+// newOIDCTest serves the api with the provider `test`, provisionRole empty disables provisioning
+func newOIDCTest(t *testing.T, provisionRole user.Role, users ...user.Entity) *oidcTest {
+	ot := oidcTest{
+		provider: oidctest.NewServer("api", "secret"),
+		users:    &memUsers{users: make(map[string]user.Entity)},
+	}
+	t.Cleanup(ot.provider.Close)
+
+	for i := range users {
+		ot.users.users[users[i].UUID] = users[i]
+	}
+
+	provisionRoles := map[string]user.Role{}
+	if provisionRole != "" {
+		provisionRoles["test"] = provisionRole
+	}
+
+	signKey, verificationKey := testKeyPair(t)
+
+	userSvc := user.NewService(ot.users, user.Config{
+		TwoFactorRepository:            memTwoFactors{},
+		TwoFactorRequirementRepository: memTwoFactorRequirements{},
+		SessionRepository:              memSessions{},
+		IdentityRepository:             &memIdentities{},
+		SigningKeyRepository:           memSigningKeys{},
+		SignKey:                        signKey,
+		VerificationKey:                verificationKey,
+		AccessTokenTTL:                 time.Hour,
+		OIDCProvisionRoles:             provisionRoles,
+	})
+
+	var h http.Handler
+
+	ot.api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ot.api.Close)
+
+	provider := oidc.NewProvider(ot.provider.Config(ot.api.URL+"/auth/oidc/test/callback"), ot.provider.Client())
+	h = NewHandler(logger.New(ioutil.Discard, logger.FormatJSON, logger.LevelError), userSvc, nil, nil, nil, nil,
+		SetOIDC(map[string]*oidc.Provider{"test": provider}, ""))
+
+	return &ot
+}
+
+type oidcCallbackResponse struct {
+	AccessToken string `json:"accessToken"`
+	UserUUID    string `json:"userUUID"`
+	Detail      string `json:"detail"`
+}
+
+// logIn signs in the user at the provider, tamper changes the query of the authorization request or the callback
+func (ot *oidcTest) logIn(t *testing.T, u oidctest.User, tamper func(endpoint string, query url.Values)) (int, oidcCallbackResponse) {
+	ot.provider.SetUser(u)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	location := ot.api.URL + "/auth/oidc/test/start"
+
+	for _, endpoint := range []string{"start", "authorize"} {
+		rsp, err := client.Get(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusFound {
+			t.Fatalf("%s responded %d, expected 302", endpoint, rsp.StatusCode)
+		}
+
+		next, err := url.Parse(rsp.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tamper != nil {
+			query := next.Query()
+			tamper(endpoint, query)
+			next.RawQuery = query.Encode()
+		}
+
+		location = next.String()
+	}
+
+	rsp, err := client.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	var res oidcCallbackResponse
+
+	err = json.NewDecoder(rsp.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rsp.StatusCode, res
+}
+
+func TestOIDCLogIn(t *testing.T) {
+	jane := user.Entity{
+		UUID:            "jane-uuid",
+		Username:        "jane",
+		Email:           "jane@example.com",
+		EmailVerifiedAt: time.Now(),
+		Role:            user.RoleAuthor,
+	}
+
+	t.Run("provisions a user", func(t *testing.T) {
+		ot := newOIDCTest(t, user.RoleAuthor)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1", Email: "john@example.com", EmailVerified: true, PreferredUsername: "john"}, nil)
+		if status != http.StatusOK || res.AccessToken == "" {
+			t.Fatalf("expected access token, got %d %+v", status, res)
+		}
+
+		entity := ot.users.users[res.UserUUID]
+		if entity.Username != "john" || entity.Role != user.RoleAuthor || !entity.EmailVerified() {
+			t.Errorf("unexpected provisioned user %+v", entity)
+		}
+
+		// the subject is linked, so the next login finds the same user
+		status, again := ot.logIn(t, oidctest.User{Subject: "1"}, nil)
+		if status != http.StatusOK || again.UserUUID != res.UserUUID {
+			t.Errorf("expected login of %s, got %d %+v", res.UserUUID, status, again)
+		}
+	})
+
+	t.Run("links a verified email", func(t *testing.T) {
+		ot := newOIDCTest(t, "", jane)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1", Email: "jane@example.com", EmailVerified: true}, nil)
+		if status != http.StatusOK || res.UserUUID != jane.UUID {
+			t.Errorf("expected login of %s, got %d %+v", jane.UUID, status, res)
+		}
+	})
+
+	t.Run("rejects an unverified email", func(t *testing.T) {
+		ot := newOIDCTest(t, user.RoleAuthor, jane)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1", Email: "jane@example.com", EmailVerified: false}, nil)
+		if status != http.StatusConflict {
+			t.Errorf("expected 409, got %d %+v", status, res)
+		}
+	})
+
+	t.Run("rejects unknown users without provisioning", func(t *testing.T) {
+		ot := newOIDCTest(t, "", jane)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1", Email: "john@example.com", EmailVerified: true}, nil)
+		if status != http.StatusForbidden {
+			t.Errorf("expected 403, got %d %+v", status, res)
+		}
+
+		if len(ot.users.users) != 1 {
+			t.Errorf("expected no provisioned user, got %d users", len(ot.users.users))
+		}
+	})
+
+	t.Run("rejects a wrong state", func(t *testing.T) {
+		ot := newOIDCTest(t, user.RoleAuthor)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1"}, func(endpoint string, query url.Values) {
+			if endpoint == "authorize" {
+				query.Set("state", "forged")
+			}
+		})
+		if status != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d %+v", status, res)
+		}
+	})
+
+	t.Run("rejects a wrong nonce", func(t *testing.T) {
+		ot := newOIDCTest(t, user.RoleAuthor)
+
+		status, res := ot.logIn(t, oidctest.User{Subject: "1"}, func(endpoint string, query url.Values) {
+			if endpoint == "start" {
+				query.Set("nonce", "forged")
+			}
+		})
+		if status != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d %+v", status, res)
+		}
+
+		if len(ot.users.users) != 0 {
+			t.Errorf("expected no provisioned user, got %d users", len(ot.users.users))
+		}
+	})
+}
@@ -1,9 +1,6 @@
 package http
 
 import (
-	"fmt"
-	"github.com/getsentry/sentry-go"
-	"log"
 	"net/http"
 )
 
@@ -14,6 +11,8 @@ type Problem struct {
 	Detail     string
 	Instance   string
 	Extensions map[string]interface{}
+	// err is the cause of internal server errors, it is logged but never sent to clients
+	err error
 }
 
 func (p Problem) MarshalJSON() ([]byte, error) {
@@ -78,15 +77,10 @@ func setExtension(key string, val interface{}) ProblemOption {
 }
 
 func internalServerError(err error, options ...ProblemOption) Problem {
-	log.Println(fmt.Sprintf("%+v", err))
-
-	id := sentry.CaptureException(err)
-
 	e := Problem{
-		Status: http.StatusInternalServerError,
-		Extensions: map[string]interface{}{
-			"tracking_code": id,
-		},
+		err:        err,
+		Status:     http.StatusInternalServerError,
+		Extensions: map[string]interface{}{},
 	}
 
 	for i := range options {
@@ -166,6 +160,20 @@ func conflict(detail string, options ...ProblemOption) Problem {
 	return e
 }
 
+func requestEntityTooLarge(detail string, options ...ProblemOption) Problem {
+	e := Problem{
+		Status:     http.StatusRequestEntityTooLarge,
+		Detail:     detail,
+		Extensions: map[string]interface{}{},
+	}
+
+	for i := range options {
+		options[i](&e)
+	}
+
+	return e
+}
+
 func unsupportedMediaType(detail string, options ...ProblemOption) Problem {
 	e := Problem{
 		Status:     http.StatusUnsupportedMediaType,
@@ -194,6 +202,20 @@ func tooManyRequests(detail string, options ...ProblemOption) Problem {
 	return e
 }
 
+func insufficientStorage(detail string, options ...ProblemOption) Problem {
+	e := Problem{
+		Status:     http.StatusInsufficientStorage,
+		Detail:     detail,
+		Extensions: map[string]interface{}{},
+	}
+
+	for i := range options {
+		options[i](&e)
+	}
+
+	return e
+}
+
 func serviceUnavailable(detail string, options ...ProblemOption) Problem {
 	e := Problem{
 		Status:     http.StatusServiceUnavailable,
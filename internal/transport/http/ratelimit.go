@@ -0,0 +1,213 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql/language/ast"
+	gqlhandler "github.com/graphql-go/handler"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/services/ratelimit"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const rateLimitDefault = "default"
+
+// rateLimitExempt are routes of probes, which must not fail when clients behind the same IP exceed the default policy
+var rateLimitExempt = map[string]bool{
+	"GET /healthz": true,
+	"GET /readyz":  true,
+}
+
+const rateLimitGraphQLPrefix = "graphql:"
+
+const maxRateLimitedGraphQLBody = 1 << 20
+
+type rateLimiter struct {
+	svc      ratelimit.Service
+	policies map[string]ratelimit.Policy
+}
+
+// allow lets requests through when the store fails, so its outage doesn't take the api down
+func (rl *rateLimiter) allow(w http.ResponseWriter, r *http.Request, target string, policy ratelimit.Policy) bool {
+	// authenticated users are limited by their uuid, so users behind a shared ip don't limit each other
+	subject := "ip:" + clientFromContext(r.Context()).IP
+	if userUUID, ok := r.Context().Value(contextKeyUserUUID).(string); ok {
+		subject = "user:" + userUUID
+	}
+
+	res, err := rl.svc.Allow(r.Context(), ratelimit.AllowRequest{
+		Key:    target + "|" + subject,
+		Policy: policy,
+	})
+	if err != nil {
+		addLogFields(r.Context(), logger.Fields{"rateLimitError": err.Error()})
+		return true
+	}
+
+	reset := strconv.Itoa(int(math.Ceil(res.Reset.Seconds())))
+
+	w.Header().Set("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	w.Header().Set("RateLimit-Reset", reset)
+
+	if res.Allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", reset)
+
+	addLogFields(r.Context(), logger.Fields{"rateLimited": target})
+
+	respond(w, r, tooManyRequests(fmt.Sprintf("rate limit of %s exceeded, retry after %s seconds", target, reset),
+		setExtension("limit", res.Limit),
+		setExtension("retryAfter", res.Reset.Seconds()),
+	))
+
+	return false
+}
+
+func (rl *rateLimiter) allowGraphQL(w http.ResponseWriter, r *http.Request, query, operationName string) bool {
+	if rl == nil {
+		return true
+	}
+
+	doc, op, ok := operationDefinition(query, operationName)
+	if !ok {
+		return true
+	}
+
+	for _, name := range rootFieldNames(doc, op.SelectionSet, make(map[string]bool)) {
+		target := rateLimitGraphQLPrefix + name
+
+		policy, ok := rl.policies[target]
+		if !ok {
+			continue
+		}
+
+		if !rl.allow(w, r, target, policy) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rootFieldNames includes fields of fragments, so fields can't escape their policies in fragments
+func rootFieldNames(doc *ast.Document, selectionSet *ast.SelectionSet, visited map[string]bool) []string {
+	if selectionSet == nil {
+		return nil
+	}
+
+	var res []string
+
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if selection.Name != nil {
+				res = append(res, selection.Name.Value)
+			}
+		case *ast.InlineFragment:
+			res = append(res, rootFieldNames(doc, selection.SelectionSet, visited)...)
+		case *ast.FragmentSpread:
+			if selection.Name == nil || visited[selection.Name.Value] {
+				continue
+			}
+
+			// fragments are visited once, cyclic fragments are rejected by the graphql validation later
+			visited[selection.Name.Value] = true
+
+			if fragment := fragmentDefinition(doc, selection.Name.Value); fragment != nil {
+				res = append(res, rootFieldNames(doc, fragment.SelectionSet, visited)...)
+			}
+		}
+	}
+
+	return res
+}
+
+func fragmentDefinition(doc *ast.Document, name string) *ast.FragmentDefinition {
+	for i := range doc.Definitions {
+		fragment, ok := doc.Definitions[i].(*ast.FragmentDefinition)
+		if ok && fragment.Name != nil && fragment.Name.Value == name {
+			return fragment
+		}
+	}
+
+	return nil
+}
+
+func (rl *rateLimiter) allowGraphQLRequest(w http.ResponseWriter, r *http.Request) bool {
+	if rl == nil {
+		return true
+	}
+
+	var body []byte
+
+	if r.Body != nil {
+		var err error
+
+		body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRateLimitedGraphQLBody))
+		if err != nil {
+			if len(body) >= maxRateLimitedGraphQLBody {
+				respond(w, r, requestEntityTooLarge(fmt.Sprintf("request body exceeds %d bytes", maxRateLimitedGraphQLBody)))
+				return false
+			}
+
+			respond(w, r, badRequest("invalid request body"))
+			return false
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	opts := gqlhandler.NewRequestOptions(r)
+
+	if r.Body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	return rl.allowGraphQL(w, r, opts.Query, opts.OperationName)
+}
+
+type rateLimitMW struct {
+	next    http.Handler
+	limiter *rateLimiter
+}
+
+func limitRate(rl *rateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if rl == nil {
+			return next
+		}
+
+		return &rateLimitMW{
+			next:    next,
+			limiter: rl,
+		}
+	}
+}
+
+func (mw *rateLimitMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Method + " " + routeTemplate(r)
+
+	if rateLimitExempt[target] {
+		mw.next.ServeHTTP(w, r)
+		return
+	}
+
+	policy, ok := mw.limiter.policies[target]
+	if !ok {
+		target = rateLimitDefault
+		policy, ok = mw.limiter.policies[target]
+	}
+
+	if ok && !mw.limiter.allow(w, r, target, policy) {
+		return
+	}
+
+	mw.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/visitor"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-CSRF-Token",
+}
+
+var defaultRedactedFields = []string{
+	"password",
+	"currentPassword",
+	"newPassword",
+	"accessToken",
+	"refreshToken",
+	"token",
+	"secret",
+	"challengeToken",
+	"code",
+	"recoveryCodes",
+	"provisioningURI",
+}
+
+type redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+	// inline matches arguments like `password: "secret"` in graphql query strings
+	inline *regexp.Regexp
+}
+
+func newRedactor(headers, fields []string) *redactor {
+	rd := redactor{
+		headers: make(map[string]bool),
+		fields:  make(map[string]bool),
+	}
+
+	for i := range headers {
+		rd.headers[http.CanonicalHeaderKey(strings.TrimSpace(headers[i]))] = true
+	}
+
+	names := make([]string, 0, len(fields))
+	for i := range fields {
+		name := strings.TrimSpace(fields[i])
+		if name == "" {
+			continue
+		}
+
+		rd.fields[strings.ToLower(name)] = true
+		names = append(names, regexp.QuoteMeta(name))
+	}
+
+	if len(names) > 0 {
+		rd.inline = regexp.MustCompile(fmt.Sprintf(`(?i)\b(%s)(\s*:\s*)"(?:[^"\\]|\\.)*"`, strings.Join(names, "|")))
+	}
+
+	return &rd
+}
+
+func (rd *redactor) Headers(h http.Header) map[string]string {
+	res := make(map[string]string, len(h))
+	for k, vv := range h {
+		if rd.headers[http.CanonicalHeaderKey(k)] {
+			res[k] = redacted
+			continue
+		}
+
+		res[k] = strings.Join(vv, ", ")
+	}
+
+	return res
+}
+
+// JSON returns nothing for bodies which aren't valid json, so their secrets aren't logged
+func (rd *redactor) JSON(body []byte) string {
+	var v interface{}
+
+	err := json.Unmarshal(body, &v)
+	if err != nil {
+		return redacted
+	}
+
+	res, err := json.Marshal(rd.value(v))
+	if err != nil {
+		return redacted
+	}
+
+	return string(res)
+}
+
+func (rd *redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		rd.graphQLVariables(v)
+
+		for k := range v {
+			if rd.fields[strings.ToLower(k)] {
+				v[k] = redacted
+				continue
+			}
+
+			if s, ok := v[k].(string); ok && k == "query" && rd.inline != nil {
+				v[k] = rd.inline.ReplaceAllString(s, `$1$2"`+redacted+`"`)
+				continue
+			}
+
+			v[k] = rd.value(v[k])
+		}
+
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = rd.value(v[i])
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+// graphQLVariables masks variables passed to redacted arguments or input fields
+func (rd *redactor) graphQLVariables(req map[string]interface{}) {
+	query, _ := req["query"].(string)
+	variables, _ := req["variables"].(map[string]interface{})
+
+	if query == "" || len(variables) == 0 {
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return
+	}
+
+	names := make(map[string]bool)
+
+	visitor.Visit(doc, &visitor.VisitorOptions{
+		Enter: func(p visitor.VisitFuncParams) (string, interface{}) {
+			switch node := p.Node.(type) {
+			case *ast.Argument:
+				if rd.fields[strings.ToLower(node.Name.Value)] {
+					variableNames(node.Value, names)
+				}
+			case *ast.ObjectField:
+				if rd.fields[strings.ToLower(node.Name.Value)] {
+					variableNames(node.Value, names)
+				}
+			}
+
+			return visitor.ActionNoChange, nil
+		},
+	}, nil)
+
+	for name := range names {
+		if _, ok := variables[name]; ok {
+			variables[name] = redacted
+		}
+	}
+}
+
+func variableNames(value ast.Value, names map[string]bool) {
+	switch value := value.(type) {
+	case *ast.Variable:
+		names[value.Name.Value] = true
+	case *ast.ObjectValue:
+		for i := range value.Fields {
+			variableNames(value.Fields[i].Value, names)
+		}
+	case *ast.ListValue:
+		for i := range value.Values {
+			variableNames(value.Values[i], names)
+		}
+	}
+}
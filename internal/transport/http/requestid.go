@@ -0,0 +1,50 @@
+package http
+
+import (
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/nasermirzaei89/api/internal/requestid"
+	"net/http"
+)
+
+const headerRequestID = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type requestIDMW struct {
+	next http.Handler
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7E {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (mw *requestIDMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(headerRequestID)
+	if !validRequestID(id) {
+		id = uuid.New().String()
+	}
+
+	r = r.WithContext(requestid.NewContext(r.Context(), id))
+	w.Header().Set(headerRequestID, id)
+
+	mw.next.ServeHTTP(w, r)
+}
+
+func requestID() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &requestIDMW{
+			next: next,
+		}
+	}
+}
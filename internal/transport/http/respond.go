@@ -1,10 +1,18 @@
 package http
 
 import (
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"github.com/nasermirzaei89/api/internal/logger"
+	"github.com/nasermirzaei89/api/internal/requestid"
 	"net/http"
 )
 
-func respond(w http.ResponseWriter, _ *http.Request, rsp interface{}) {
+func respond(w http.ResponseWriter, r *http.Request, rsp interface{}) {
+	if p, ok := rsp.(Problem); ok {
+		rsp = problemWithRequestID(r, p)
+	}
+
 	if rsp == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
@@ -34,6 +42,41 @@ func respond(w http.ResponseWriter, _ *http.Request, rsp interface{}) {
 	_ = json.NewEncoder(w).Encode(rsp)
 }
 
+func problemWithRequestID(r *http.Request, p Problem) Problem {
+	id := requestid.FromContext(r.Context())
+
+	extensions := make(map[string]interface{}, len(p.Extensions)+2)
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+
+	if id != "" {
+		extensions["request_id"] = id
+	}
+
+	if p.err != nil {
+		var trackingCode *sentry.EventID
+
+		sentry.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("request_id", id)
+			scope.SetRequest(r)
+			trackingCode = sentry.CaptureException(p.err)
+		})
+
+		extensions["tracking_code"] = trackingCode
+
+		addLogFields(r.Context(), logger.Fields{
+			"error":        p.err.Error(),
+			"stack":        fmt.Sprintf("%+v", p.err),
+			"trackingCode": trackingCode,
+		})
+	}
+
+	p.Extensions = extensions
+
+	return p
+}
+
 type withStatusCreated struct{}
 
 func (withStatusCreated) StatusCode() int {
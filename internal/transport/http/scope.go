@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"github.com/graphql-go/graphql"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+)
+
+// graphQLScopes are root fields api tokens can use, empty scopes are public or about the user
+var graphQLScopes = map[string]user.Scope{
+	"Query.health":                 "",
+	"Query.node":                   "",
+	"Query.me":                     "",
+	"Query.userByUsername":         "",
+	"Query.getPublishedPostBySlug": "",
+	"Query.listPublishedPosts":     "",
+	"Query.getPostByUUID":          user.ScopePostsRead,
+	"Query.listPosts":              user.ScopePostsRead,
+	"Query.myStorageUsage":         user.ScopeFilesRead,
+	"Mutation.createPost":          user.ScopePostsWrite,
+	"Mutation.updatePostByUUID":    user.ScopePostsWrite,
+	"Mutation.publishPostByUUID":   user.ScopePostsWrite,
+	"Mutation.uploadFile":          user.ScopeFilesWrite,
+}
+
+func checkScope(ctx context.Context, scope user.Scope) error {
+	apiToken, ok := ctx.Value(contextKeyAPIToken).(*user.APIToken)
+	if !ok || scope == "" {
+		return nil
+	}
+
+	if !apiToken.HasScope(scope) {
+		return errors.Errorf("api token doesn't have the '%s' scope", scope)
+	}
+
+	return nil
+}
+
+func authorizeScopes(schema *graphql.Schema) {
+	for _, obj := range []*graphql.Object{schema.QueryType(), schema.MutationType()} {
+		for _, field := range obj.Fields() {
+			if field.Resolve == nil {
+				continue
+			}
+
+			field.Resolve = authorizeScope(obj.Name()+"."+field.Name, field.Resolve)
+		}
+	}
+}
+
+func authorizeScope(name string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	scope, allowed := graphQLScopes[name]
+
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if _, ok := p.Context.Value(contextKeyAPIToken).(*user.APIToken); ok && !allowed {
+			return nil, errors.Errorf("api tokens can't use '%s', use an access token", p.Info.FieldName)
+		}
+
+		err := checkScope(p.Context, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		return resolve(p)
+	}
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/nasermirzaei89/api/internal/services/user"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+const (
+	sessionCookieName = "session"
+	csrfCookieName    = "csrf_token"
+	headerCSRFToken   = "X-CSRF-Token"
+	csrfTokenSize     = 32
+)
+
+type sessionCookies struct {
+	domain         string
+	allowedOrigins map[string]bool
+	trustProxy     bool
+}
+
+func newSessionCookies(domain string, allowedOrigins []string, trustProxy bool) *sessionCookies {
+	c := sessionCookies{
+		domain:         domain,
+		allowedOrigins: make(map[string]bool, len(allowedOrigins)),
+		trustProxy:     trustProxy,
+	}
+
+	for i := range allowedOrigins {
+		c.allowedOrigins[allowedOrigins[i]] = true
+	}
+
+	return &c
+}
+
+func (c *sessionCookies) cookie(r *http.Request, name, value string, maxAge int, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   c.domain,
+		MaxAge:   maxAge,
+		Secure:   isHTTPS(r, c.trustProxy),
+		HttpOnly: httpOnly,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+func (c *sessionCookies) set(w http.ResponseWriter, r *http.Request, accessToken string) error {
+	b := make([]byte, csrfTokenSize)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return errors.Wrap(errors.WithStack(err), "error on read random bytes")
+	}
+
+	http.SetCookie(w, c.cookie(r, sessionCookieName, accessToken, 0, true))
+	http.SetCookie(w, c.cookie(r, csrfCookieName, base64.RawURLEncoding.EncodeToString(b), 0, false))
+
+	return nil
+}
+
+func (c *sessionCookies) clear(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, c.cookie(r, sessionCookieName, "", -1, true))
+	http.SetCookie(w, c.cookie(r, csrfCookieName, "", -1, false))
+}
+
+// validCSRFToken works since other sites can't read the csrf cookie
+func (c *sessionCookies) validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.Header.Get(headerCSRFToken))) == 1
+}
+
+func (c *sessionCookies) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || c.allowedOrigins[origin] {
+		return true
+	}
+
+	scheme := "http"
+	if isHTTPS(r, c.trustProxy) {
+		scheme = "https"
+	}
+
+	return origin == scheme+"://"+r.Host
+}
+
+type sessionWriter struct {
+	cookies *sessionCookies
+	w       http.ResponseWriter
+	r       *http.Request
+}
+
+// logins of other origins can't set cookies, so other sites can't log in browsers as someone else
+func sessionWriterFromContext(ctx context.Context) (*sessionWriter, error) {
+	sw, ok := ctx.Value(contextKeySessionWriter).(*sessionWriter)
+	if !ok {
+		return nil, errors.New("session cookies are disabled")
+	}
+
+	if !sw.cookies.allowedOrigin(sw.r) {
+		return nil, errors.New("origin is not allowed to use session cookies")
+	}
+
+	return sw, nil
+}
+
+func (sw *sessionWriter) setAccessToken(res *user.LogInResponse) (*user.LogInResponse, error) {
+	if sw == nil || res.AccessToken == "" {
+		return res, nil
+	}
+
+	err := sw.cookies.set(sw.w, sw.r, res.AccessToken)
+	if err != nil {
+		return nil, errors.Wrap(err, "error on set session cookies")
+	}
+
+	rsp := *res
+	rsp.AccessToken = ""
+
+	return &rsp, nil
+}
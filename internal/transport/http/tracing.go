@@ -0,0 +1,121 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/graphql-go/graphql"
+	"github.com/nasermirzaei89/api/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+const serverName = "api"
+
+type tracingMW struct {
+	next http.Handler
+}
+
+func traceRequests() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return &tracingMW{
+			next: next,
+		}
+	}
+}
+
+func (mw *tracingMW) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), r.Header)
+
+	route := routeTemplate(r)
+
+	ctx, span := tracing.Start(ctx, fmt.Sprintf("HTTP %s %s", r.Method, route),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, route, r)...),
+		trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", r)...),
+		trace.WithAttributes(semconv.EndUserAttributesFromHTTPRequest(r)...),
+	)
+	defer span.End()
+
+	crw := &customRW{rw: w, statusCode: http.StatusOK, body: new(bytes.Buffer)}
+
+	mw.next.ServeHTTP(crw, r.WithContext(ctx))
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(crw.statusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(crw.statusCode))
+}
+
+func startGraphQLSpan(ctx context.Context) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "graphql")
+}
+
+func endGraphQLSpan(span trace.Span, name, typ string, result *graphql.Result) {
+	span.SetName(fmt.Sprintf("graphql %s %s", typ, name))
+	span.SetAttributes(
+		label.String("graphql.operation.name", name),
+		label.String("graphql.operation.type", typ),
+	)
+
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for i := range result.Errors {
+			messages[i] = result.Errors[i].Message
+		}
+
+		span.SetAttributes(label.Int("graphql.errors", len(result.Errors)))
+		span.SetStatus(codes.Error, strings.Join(messages, "; "))
+	}
+
+	span.End()
+}
+
+func traceResolvers(schema *graphql.Schema) {
+	for typeName, t := range schema.TypeMap() {
+		obj, ok := t.(*graphql.Object)
+		if !ok || strings.HasPrefix(typeName, "__") {
+			continue
+		}
+
+		for _, field := range obj.Fields() {
+			if field.Resolve == nil {
+				continue
+			}
+
+			field.Resolve = traceResolver(typeName, field.Name, field.Resolve)
+		}
+	}
+}
+
+func traceResolver(typeName, fieldName string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	spanName := fmt.Sprintf("graphql resolve %s.%s", typeName, fieldName)
+
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx, span := tracing.Start(p.Context, spanName, trace.WithAttributes(
+			label.String("graphql.field.parent", typeName),
+			label.String("graphql.field.name", fieldName),
+			label.String("graphql.field.path", resolvePath(p.Info.Path)),
+		))
+
+		p.Context = ctx
+
+		res, err := resolve(p)
+		tracing.End(span, err)
+
+		return res, err
+	}
+}
+
+func resolvePath(path *graphql.ResponsePath) string {
+	var parts []string
+	for ; path != nil; path = path.Prev {
+		parts = append([]string{fmt.Sprint(path.Key)}, parts...)
+	}
+
+	return strings.Join(parts, ".")
+}